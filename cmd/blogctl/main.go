@@ -0,0 +1,44 @@
+// Command blogctl runs maintenance tasks against the blog's database and
+// file storage, using the same configuration as the server.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: blogctl <command> [arguments]
+
+Commands:
+  storage gc        Report or delete content files no post references
+  storage convert   Move post content to the content-addressed layout
+  storage migrate   Copy stored files to another storage type, e.g. local to s3
+  posts slugs       Give posts created before slugs existed one from their title
+`
+
+func main() {
+	if len(os.Args) < 3 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] + " " + os.Args[2] {
+	case "storage gc":
+		err = runStorageGC(os.Args[3:])
+	case "storage convert":
+		err = runStorageConvert(os.Args[3:])
+	case "storage migrate":
+		err = runStorageMigrate(os.Args[3:])
+	case "posts slugs":
+		err = runPostsSlugs(os.Args[3:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "blogctl: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"go-blog/internal/config"
+	"go-blog/internal/service"
+	"go-blog/internal/store/postgres"
+)
+
+func runPostsSlugs(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("could not load config: %w", err)
+	}
+
+	fs := flag.NewFlagSet("posts slugs", flag.ExitOnError)
+	fs.Parse(args)
+
+	db, err := postgres.New(cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %w", err)
+	}
+	defer db.Close()
+
+	backfill := service.NewSlugBackfill(postgres.NewPostStore(db), postgres.NewUnitOfWork(db))
+	named, err := backfill.Run()
+	fmt.Printf("named %d posts\n", named)
+	return err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"go-blog/internal/config"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"go-blog/internal/store/postgres"
+)
+
+func runStorageGC(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("could not load config: %w", err)
+	}
+
+	fs := flag.NewFlagSet("storage gc", flag.ExitOnError)
+	grace := fs.Duration("grace", cfg.ContentGCGracePeriod, "ignore files modified more recently than this")
+	deleteOrphans := fs.Bool("delete", false, "delete orphaned files instead of only reporting them")
+	fs.Parse(args)
+
+	db, err := postgres.New(cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %w", err)
+	}
+	defer db.Close()
+
+	storageResolver, err := uncachedResolver(cfg)
+	if err != nil {
+		return fmt.Errorf("could not initialize file storage: %w", err)
+	}
+
+	gc := service.NewContentGC(postgres.NewPostStore(db), storageResolver, *grace, *deleteOrphans)
+	report, err := gc.Sweep()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range report.Orphans {
+		fmt.Printf("%s\t%d bytes\t%s\n", f.Path, f.Size, f.ModTime.Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("scanned %d files, found %d orphans, deleted %d\n", report.Scanned, len(report.Orphans), report.Deleted)
+	return nil
+}
+
+func runStorageConvert(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("could not load config: %w", err)
+	}
+
+	fs := flag.NewFlagSet("storage convert", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report what would be converted without writing anything")
+	fs.Parse(args)
+
+	db, err := postgres.New(cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %w", err)
+	}
+	defer db.Close()
+
+	storageResolver, err := uncachedResolver(cfg)
+	if err != nil {
+		return fmt.Errorf("could not initialize file storage: %w", err)
+	}
+
+	converter := service.NewContentConverter(postgres.NewPostStore(db), postgres.NewUnitOfWork(db), storageResolver, *dryRun)
+	report, err := converter.Run()
+	if report != nil {
+		fmt.Printf("converted %d files into %d blobs\n", report.Converted, report.Blobs)
+	}
+	if err != nil {
+		return err
+	}
+	if !*dryRun && report.Converted > 0 {
+		fmt.Println("the old files are no longer referenced; run 'blogctl storage gc -delete' to remove them")
+	}
+	return nil
+}
+
+func runStorageMigrate(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("could not load config: %w", err)
+	}
+
+	fs := flag.NewFlagSet("storage migrate", flag.ExitOnError)
+	from := fs.String("from", cfg.StorageType, "storage type to copy from")
+	to := fs.String("to", "", "storage type to copy to")
+	dryRun := fs.Bool("dry-run", false, "report what would be copied without writing anything")
+	fs.Parse(args)
+
+	if *to == "" || *to == *from {
+		return fmt.Errorf("-to must name a storage type other than %s", *from)
+	}
+
+	db, err := postgres.New(cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %w", err)
+	}
+	defer db.Close()
+
+	source, err := migrationResolver(cfg, *from)
+	if err != nil {
+		return fmt.Errorf("could not initialize %s storage: %w", *from, err)
+	}
+	destination, err := migrationResolver(cfg, *to)
+	if err != nil {
+		return fmt.Errorf("could not initialize %s storage: %w", *to, err)
+	}
+
+	migrator := service.NewStorageMigrator(postgres.NewPostStore(db), postgres.NewMediaStore(db), source, destination, *dryRun)
+	report, err := migrator.Run()
+	if report != nil {
+		for _, path := range report.Missing {
+			fmt.Printf("missing from %s: %s\n", *from, path)
+		}
+		fmt.Printf("%d files: copied %d, already present %d, missing %d\n", report.Total, report.Copied, report.Skipped, len(report.Missing))
+	}
+	return err
+}
+
+// migrationResolver builds the resolver for storageType with the rest of
+// the configuration unchanged, so paths and encryption match the server's.
+func migrationResolver(cfg *config.Config, storageType string) (storage.Resolver, error) {
+	c := *cfg
+	c.StorageType = storageType
+	// Paths are the same in every isolation mode, so the non-S3 side of a
+	// migration to or from per-user buckets can use the prefix layout.
+	if c.StorageIsolation == "bucket" && storageType != "s3" {
+		c.StorageIsolation = "prefix"
+	}
+	return uncachedResolver(&c)
+}
+
+// uncachedResolver builds the resolver cfg describes without the read
+// cache. The commands read every file once, so a cache would only take up
+// memory, and opening the disk cache would empty the running server's.
+func uncachedResolver(cfg *config.Config) (storage.Resolver, error) {
+	c := *cfg
+	c.StorageCacheSize, c.StorageCacheDir = 0, ""
+	return storage.NewResolver(&c)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/labstack/echo/v4"
@@ -32,16 +33,65 @@ func main() {
 	// Initialize stores
 	userStore := postgres.NewUserStore(db)
 	postStore := postgres.NewPostStore(db)
+	unitOfWork := postgres.NewUnitOfWork(db)
+	mediaStore := postgres.NewMediaStore(db)
 
 	// Initialize file storage
-	fileStorage, err := storage.New(cfg)
+	storageResolver, err := storage.NewResolver(cfg)
 	if err != nil {
 		log.Fatalf("could not initialize file storage: %v", err)
 	}
 
+	contentLayout, err := service.ParseContentLayout(cfg.ContentLayout)
+	if err != nil {
+		log.Fatalf("invalid CONTENT_LAYOUT: %v", err)
+	}
+
 	// Initialize services
 	userService := service.NewUserService(userStore)
-	postService := service.NewPostService(postStore, fileStorage)
+	postService := service.NewPostService(postStore, unitOfWork, storageResolver, contentLayout)
+	mediaService := service.NewMediaService(mediaStore, storageResolver, cfg.MediaMaxSize)
+
+	// Start background jobs
+	if cfg.ContentGCInterval > 0 {
+		contentGC := service.NewContentGC(postStore, storageResolver, cfg.ContentGCGracePeriod, cfg.ContentGCDelete)
+		go service.RunEvery(context.Background(), "content gc", cfg.ContentGCInterval, func() error {
+			report, err := contentGC.Sweep()
+			if err != nil {
+				return err
+			}
+			log.Printf("content gc: scanned %d files, found %d orphans, deleted %d", report.Scanned, len(report.Orphans), report.Deleted)
+			return nil
+		})
+	}
+
+	if cfg.TrashPurgeInterval > 0 {
+		trashPurger := service.NewTrashPurger(postStore, storageResolver, cfg.TrashRetention)
+		go service.RunEvery(context.Background(), "trash purge", cfg.TrashPurgeInterval, func() error {
+			report, err := trashPurger.Purge()
+			if err != nil {
+				return err
+			}
+			if report.Posts > 0 {
+				log.Printf("trash purge: removed %d posts and %d files", report.Posts, report.Files)
+			}
+			return nil
+		})
+	}
+
+	if cfg.SchedulerInterval > 0 {
+		scheduler := service.NewPostScheduler(postStore)
+		go service.RunEvery(context.Background(), "post scheduler", cfg.SchedulerInterval, func() error {
+			n, err := scheduler.PublishDue()
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("post scheduler: published %d posts", n)
+			}
+			return nil
+		})
+	}
 
 	// Initialize Echo
 	e := echo.New()
@@ -58,18 +108,32 @@ func main() {
 	e.Use(i18nmiddleware.I18n(language.English))
 	e.Use(i18nmiddleware.WebAuth(userService, cfg))
 
-	webHandler := api.NewWebHandler(cfg, postService, userService)
+	webHandler := api.NewWebHandler(cfg, postService, userService, mediaService)
 	e.HTTPErrorHandler = webHandler.CustomHTTPErrorHandler
 
-	e.Renderer = web.NewTemplateRenderer()
+	e.Renderer = web.NewTemplateRenderer(mediaService.GetByID)
 	e.GET("/posts/:id", webHandler.RenderPostPage)
+	e.GET("/posts/:id/diff", webHandler.RenderDiffPage)
 	e.GET("/", webHandler.RenderIndexPage)
+	e.GET("/tags/:tag", webHandler.RenderTagPage)
+	e.GET("/authors/:username", webHandler.RenderAuthorPage)
 	//e.GET("/login", webHandler.RenderLoginPage)
 	//e.POST("/login", webHandler.HandleLogin)
 	//e.GET("/logout", webHandler.HandleLogout)
 
+	// Local storage has no URLs of its own, so serve its signed URLs here.
+	if cfg.StorageType == "local" {
+		signer, err := storage.LocalURLSigner(cfg)
+		if err != nil {
+			log.Printf("local file URLs disabled, files are streamed by the app: %v", err)
+		} else {
+			fileHandler := api.NewFileHandler(storageResolver, mediaService)
+			e.GET(storage.LocalURLPrefix+"*", fileHandler.ServeFile, i18nmiddleware.SignedURL(signer))
+		}
+	}
+
 	// Register routes
-	api.RegisterRoutes(e, userService, postService, cfg)
+	api.RegisterRoutes(e, userService, postService, mediaService, cfg)
 
 	// Start server
 	e.Logger.Fatal(e.Start(":" + cfg.ServerPort))
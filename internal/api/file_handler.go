@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"go-blog/internal/middleware"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// FileHandler serves files behind signed URLs for storages that have no
+// URLs of their own, i.e. LocalStorage.
+type FileHandler struct {
+	storage      storage.Resolver
+	mediaService service.MediaService
+}
+
+func NewFileHandler(resolver storage.Resolver, ms service.MediaService) *FileHandler {
+	return &FileHandler{storage: resolver, mediaService: ms}
+}
+
+// ServeFile must run behind middleware.SignedURL, which verifies the URL.
+func (h *FileHandler) ServeFile(c echo.Context) error {
+	filePath, _ := c.Get(middleware.SignedPathContextKey).(string)
+	userID, ok := storage.Owner(filePath)
+	if !ok {
+		return echo.ErrNotFound
+	}
+
+	fs, err := h.storage.ForUser(userID)
+	if err != nil {
+		return err
+	}
+	r, err := fs.ReadStream(filePath)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return echo.ErrNotFound
+		}
+		return err
+	}
+	defer r.Close()
+
+	// Files are served from our own origin, so their type must never be
+	// sniffed: markdown starting with <script> would otherwise be HTML.
+	header := c.Response().Header()
+	contentType, ok := h.contentType(userID, filePath)
+	if !ok {
+		header.Set(echo.HeaderContentDisposition, "attachment")
+	}
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	// Local files are plain *os.File values, which allows range requests.
+	if rs, ok := r.(io.ReadSeeker); ok {
+		http.ServeContent(c.Response(), c.Request(), path.Base(filePath), time.Time{}, rs)
+		return nil
+	}
+	return c.Stream(http.StatusOK, contentType, r)
+}
+
+// contentType returns the type to serve a file with and whether it is safe
+// to show inline. Post content is plain text and media has the type it was
+// recorded with on upload; anything else is an opaque download.
+func (h *FileHandler) contentType(userID int, filePath string) (string, bool) {
+	if path.Ext(filePath) == ".md" {
+		return "text/plain; charset=utf-8", true
+	}
+	if media, err := h.mediaService.GetByPath(filePath); err == nil && media.UserID == userID {
+		return media.ContentType, true
+	}
+	return echo.MIMEOctetStream, false
+}
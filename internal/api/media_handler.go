@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"go-blog/internal/model"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+type MediaHandler struct {
+	mediaService service.MediaService
+	urlTTL       time.Duration
+}
+
+// NewMediaHandler creates a MediaHandler. Media is served by redirecting to
+// a signed storage URL valid for urlTTL when the storage supports it.
+func NewMediaHandler(ms service.MediaService, urlTTL time.Duration) *MediaHandler {
+	return &MediaHandler{mediaService: ms, urlTTL: urlTTL}
+}
+
+// UploadMedia stores the image sent in the "file" form field.
+func (h *MediaHandler) UploadMedia(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "file is required"})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	media, err := h.mediaService.Upload(userID, file.Filename, src)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnsupportedMediaType):
+			return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+		case errors.Is(err, service.ErrTooLarge):
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, map[string]interface{}{
+		"media": media,
+		"url":   mediaURL(media.ID),
+	})
+}
+
+// ServeMedia streams a media file from storage. The optional "size" query
+// parameter selects a resized variant such as "thumb".
+func (h *MediaHandler) ServeMedia(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid media ID"})
+	}
+
+	size := c.QueryParam("size")
+	url, err := h.mediaService.URL(id, size, h.urlTTL)
+	if err == nil {
+		return redirectToStorage(c, url, h.urlTTL)
+	}
+	if !errors.Is(err, storage.ErrURLNotSupported) {
+		return mediaError(c, err)
+	}
+
+	// The storage can't hand out URLs, so proxy the bytes instead.
+	var media *model.Media
+	var r io.ReadCloser
+	if size != "" {
+		media, r, err = h.mediaService.OpenVariant(id, size)
+	} else {
+		media, r, err = h.mediaService.Open(id)
+	}
+	if err != nil {
+		return mediaError(c, err)
+	}
+	defer r.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(media.Size, 10))
+	// A media ID always refers to the same bytes, so it can be cached forever.
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	header.Set("X-Content-Type-Options", "nosniff")
+	return c.Stream(http.StatusOK, media.ContentType, r)
+}
+
+func mediaError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Media not found"})
+	case errors.Is(err, service.ErrUnknownVariant):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// redirectToStorage redirects to a signed storage URL. Browsers may reuse
+// the redirect for half the URL's lifetime, so it never points at an
+// expired URL.
+func redirectToStorage(c echo.Context, url string, ttl time.Duration) error {
+	c.Response().Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(ttl.Seconds()/2)))
+	return c.Redirect(http.StatusFound, url)
+}
+
+func mediaURL(id int) string {
+	return "/media/" + strconv.Itoa(id)
+}
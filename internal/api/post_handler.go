@@ -1,11 +1,15 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"go-blog/internal/model"
 	"go-blog/internal/service"
-	"io"
+	"go-blog/internal/storage"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 	"log" // Added log import
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,10 +18,13 @@ import (
 
 type PostHandler struct {
 	postService service.PostService
+	urlTTL      time.Duration
 }
 
-func NewPostHandler(ps service.PostService) *PostHandler {
-	return &PostHandler{postService: ps}
+// NewPostHandler creates a PostHandler. Raw markdown downloads redirect to a
+// signed storage URL valid for urlTTL when the storage supports it.
+func NewPostHandler(ps service.PostService, urlTTL time.Duration) *PostHandler {
+	return &PostHandler{postService: ps, urlTTL: urlTTL}
 }
 
 type CreatePostRequest struct {
@@ -26,6 +33,64 @@ type CreatePostRequest struct {
 	Image    string   `json:"image"`
 	Tags     []string `json:"tags"`
 	Content  string   `json:"content"` // Markdown content
+	// Status defaults to published. PublishAt is required for scheduled posts.
+	Status    model.PostStatus `json:"status"`
+	PublishAt *time.Time       `json:"publish_at"`
+}
+
+type SetPostStatusRequest struct {
+	Status    model.PostStatus `json:"status"`
+	PublishAt *time.Time       `json:"publish_at"`
+}
+
+// resolvePost reads the :id route parameter, which is either a post ID or
+// a slug. When it is an old slug of a renamed post, it also returns the
+// URL of the request with the current slug, for the caller to redirect to.
+func resolvePost(c echo.Context, ps service.PostService) (int, string, error) {
+	param := c.Param("id")
+	if id, err := strconv.Atoi(param); err == nil {
+		return id, "", nil
+	}
+	id, current, err := ps.ResolveSlug(param, viewerID(c))
+	if err != nil {
+		return 0, "", err
+	}
+	if current == param {
+		return id, "", nil
+	}
+	return id, withPostParam(c, current), nil
+}
+
+// withPostParam returns the URL of the current request with the :id route
+// parameter replaced by value.
+func withPostParam(c echo.Context, value string) string {
+	segments := strings.Split(c.Request().URL.Path, "/")
+	for i, name := range strings.Split(c.Path(), "/") {
+		if name == ":id" && i < len(segments) {
+			segments[i] = value
+		}
+	}
+	u := *c.Request().URL
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+	return u.RequestURI()
+}
+
+// viewerID returns the ID of the user making the request, or 0 for
+// anonymous requests. Routes behind the JWT middleware carry the token;
+// elsewhere the user may have been loaded from the session cookie.
+func viewerID(c echo.Context) int {
+	switch user := c.Get("user").(type) {
+	case *jwt.Token:
+		if claims, ok := user.Claims.(jwt.MapClaims); ok {
+			if id, ok := claims["id"].(float64); ok {
+				return int(id)
+			}
+		}
+	case *model.User:
+		return user.ID
+	}
+	return 0
 }
 
 type UpdatePostRequest struct {
@@ -47,8 +112,11 @@ func (h *PostHandler) CreatePost(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
 	}
 
-	post, err := h.postService.Create(req.Title, req.SubTitle, req.Image, req.Tags, req.Content, userID)
+	post, err := h.postService.Create(req.Title, req.SubTitle, req.Image, req.Tags, req.Content, req.Status, req.PublishAt, userID)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidStatus) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
@@ -81,24 +149,73 @@ func (h *PostHandler) UpdatePost(c echo.Context) error {
 	return c.JSON(http.StatusOK, post)
 }
 
-func (h *PostHandler) GetPost(c echo.Context) error {
+func (h *PostHandler) RestorePostVersion(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid post ID"})
 	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid version"})
+	}
 
-	post, content, err := h.postService.GetByID(id)
+	post, err := h.postService.Restore(id, version, userID)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Version not found"})
+		case errors.Is(err, service.ErrPermissionDenied):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"post":    post,
-		"content": content,
-	})
+	return c.JSON(http.StatusOK, post)
 }
 
-func (h *PostHandler) ListPosts(c echo.Context) error {
+// SetPostStatus publishes, schedules, archives or unpublishes a post.
+func (h *PostHandler) SetPostStatus(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid post ID"})
+	}
+
+	var req SetPostStatusRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request"})
+	}
+
+	post, err := h.postService.SetStatus(id, userID, req.Status, req.PublishAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+		case errors.Is(err, service.ErrPermissionDenied):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidStatus):
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, post)
+}
+
+// ListMyPosts lists the current user's posts, drafts and scheduled ones
+// included.
+func (h *PostHandler) ListMyPosts(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
 	page, _ := strconv.Atoi(c.QueryParam("page"))
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
 	if page < 1 {
@@ -108,33 +225,296 @@ func (h *PostHandler) ListPosts(c echo.Context) error {
 		limit = 10
 	}
 
-	posts, err := h.postService.List(page, limit)
+	posts, err := h.postService.ListOwn(userID, page, limit)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	if posts == nil {
+		posts = []*model.Post{}
+	}
 	return c.JSON(http.StatusOK, posts)
 }
 
+// DeletePost moves a post to the trash.
+func (h *PostHandler) DeletePost(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid post ID"})
+	}
+
+	if err := h.postService.Delete(id, userID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+		case errors.Is(err, service.ErrPermissionDenied):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListTrash lists the current user's deleted posts.
+func (h *PostHandler) ListTrash(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	posts, err := h.postService.Trash(userID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if posts == nil {
+		posts = []*model.Post{}
+	}
+	return c.JSON(http.StatusOK, posts)
+}
+
+// UndeletePost restores a post from the trash.
+func (h *PostHandler) UndeletePost(c echo.Context) error {
+	userToken := c.Get("user").(*jwt.Token)
+	claims := userToken.Claims.(jwt.MapClaims)
+	userID := int(claims["id"].(float64))
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid post ID"})
+	}
+
+	post, err := h.postService.Undelete(id, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found in trash"})
+		case errors.Is(err, service.ErrPermissionDenied):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, post)
+}
+
+func (h *PostHandler) GetPost(c echo.Context) error {
+	id, redirect, err := resolvePost(c, h.postService)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+	}
+	if redirect != "" {
+		return c.Redirect(http.StatusMovedPermanently, redirect)
+	}
+
+	post, content, err := h.postService.GetByID(id, viewerID(c))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"post":    post,
+		"content": content,
+	})
+}
+
+// GetPostMarkdown downloads the markdown of the current version of a post.
+func (h *PostHandler) GetPostMarkdown(c echo.Context) error {
+	id, redirect, err := resolvePost(c, h.postService)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+	}
+	if redirect != "" {
+		return c.Redirect(http.StatusMovedPermanently, redirect)
+	}
+
+	url, err := h.postService.ContentURL(id, h.urlTTL, viewerID(c))
+	if err == nil {
+		return redirectToStorage(c, url, h.urlTTL)
+	}
+	if errors.Is(err, service.ErrNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+	}
+	if !errors.Is(err, storage.ErrURLNotSupported) {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	_, content, err := h.postService.GetByID(id, viewerID(c))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+	}
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	return c.Blob(http.StatusOK, "text/markdown; charset=utf-8", []byte(content))
+}
+
+func (h *PostHandler) GetPostHistory(c echo.Context) error {
+	id, redirect, err := resolvePost(c, h.postService)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+	}
+	if redirect != "" {
+		return c.Redirect(http.StatusMovedPermanently, redirect)
+	}
+
+	history, err := h.postService.GetHistory(id, viewerID(c))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, history)
+}
+
+func (h *PostHandler) GetPostVersion(c echo.Context) error {
+	id, redirect, err := resolvePost(c, h.postService)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+	}
+	if redirect != "" {
+		return c.Redirect(http.StatusMovedPermanently, redirect)
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid version"})
+	}
+
+	history, content, err := h.postService.GetVersion(id, version, viewerID(c))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Version not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"version": history,
+		"content": content,
+	})
+}
+
+func (h *PostHandler) DiffPostVersions(c echo.Context) error {
+	id, redirect, err := resolvePost(c, h.postService)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Post not found"})
+	}
+	if redirect != "" {
+		return c.Redirect(http.StatusMovedPermanently, redirect)
+	}
+	from, err := strconv.Atoi(c.QueryParam("from"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Query parameter 'from' must be a version number"})
+	}
+	to, err := strconv.Atoi(c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Query parameter 'to' must be a version number"})
+	}
+
+	postDiff, err := h.postService.Diff(id, from, to, viewerID(c))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "Version not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, postDiff)
+}
+
+// ListPosts pages through public posts, newest first. Pass the
+// next_cursor or prev_cursor of a page as cursor to get the pages around
+// it, and total=true to have the posts counted. The posts can be filtered
+// by tags, tag_match (any or all), user_id, created_after and
+// created_before.
+func (h *PostHandler) ListPosts(c echo.Context) error {
+	filter, err := parsePostFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	withTotal, _ := strconv.ParseBool(c.QueryParam("total"))
+
+	page, err := h.postService.List(filter, c.QueryParam("cursor"), limit, withTotal)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// parsePostFilter reads the filter query parameters of ListPosts. Tags are
+// comma separated, and times are RFC 3339 or plain dates.
+func parsePostFilter(c echo.Context) (model.PostFilter, error) {
+	var filter model.PostFilter
+	for _, tag := range strings.Split(c.QueryParam("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+
+	switch c.QueryParam("tag_match") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		return filter, errors.New("tag_match must be 'any' or 'all'")
+	}
+
+	if v := c.QueryParam("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("user_id must be a number")
+		}
+		filter.UserID = id
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseTimeParam(c, "created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = parseTimeParam(c, "created_before"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseTimeParam reads an optional query parameter holding an RFC 3339 time
+// or a date, which stands for its midnight in UTC.
+func parseTimeParam(c echo.Context, name string) (*time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("%s must be an RFC 3339 time or a YYYY-MM-DD date", name)
+}
+
+// SearchPosts pages through public posts matching q, best match first,
+// like ListPosts.
 func (h *PostHandler) SearchPosts(c echo.Context) error {
 	query := c.QueryParam("q")
 	if query == "" {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Search query 'q' is required"})
 	}
 
-	page, _ := strconv.Atoi(c.QueryParam("page"))
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 {
-		limit = 10
-	}
+	withTotal, _ := strconv.ParseBool(c.QueryParam("total"))
 
-	posts, err := h.postService.Search(query, page, limit)
+	page, err := h.postService.Search(query, c.QueryParam("cursor"), limit, withTotal)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	return c.JSON(http.StatusOK, posts)
+	return c.JSON(http.StatusOK, page)
 }
 
 func (h *PostHandler) CreateFromUpload(c echo.Context) error {
@@ -158,6 +538,16 @@ func (h *PostHandler) CreateFromUpload(c echo.Context) error {
 		}
 	}
 
+	status := model.PostStatus(c.FormValue("status"))
+	var publishAt *time.Time
+	if v := c.FormValue("publish_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "publish_at must be an RFC 3339 time"})
+		}
+		publishAt = &t
+	}
+
 	file, err := c.FormFile("contentFile")
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "contentFile is required"})
@@ -169,13 +559,12 @@ func (h *PostHandler) CreateFromUpload(c echo.Context) error {
 	}
 	defer src.Close()
 
-	content, err := io.ReadAll(src)
-	if err != nil {
-		return err
-	}
-
-	post, err := h.postService.CreateFromFile(title, subTitle, image, tags, content, userID)
+	// Stream the upload straight into storage rather than buffering it.
+	post, err := h.postService.CreateFromFile(title, subTitle, image, tags, src, status, publishAt, userID)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidStatus) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
 
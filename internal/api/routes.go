@@ -9,9 +9,13 @@ import (
 )
 
 // RegisterRoutes sets up all the routes for the application.
-func RegisterRoutes(e *echo.Echo, userService service.UserService, postService service.PostService, cfg *config.Config) {
+func RegisterRoutes(e *echo.Echo, userService service.UserService, postService service.PostService, mediaService service.MediaService, cfg *config.Config) {
 	userHandler := NewUserHandler(userService)
-	postHandler := NewPostHandler(postService)
+	postHandler := NewPostHandler(postService, cfg.StorageURLTTL)
+	mediaHandler := NewMediaHandler(mediaService, cfg.StorageURLTTL)
+
+	// Uploaded media is served outside the API so it can be used in <img> tags.
+	e.GET("/media/:id", mediaHandler.ServeMedia)
 
 	// API group
 	apiGroup := e.Group("/api")
@@ -20,17 +24,38 @@ func RegisterRoutes(e *echo.Echo, userService service.UserService, postService s
 	apiGroup.POST("/register", userHandler.Register)
 	apiGroup.POST("/login", userHandler.Login(cfg))
 
+	// Single posts are public, but owners also see their drafts, so a token
+	// is used when one is sent.
+	optionalAuth := echojwt.WithConfig(echojwt.Config{
+		SigningKey:             []byte(cfg.JWTSecret),
+		ContinueOnIgnoredError: true,
+		ErrorHandler: func(c echo.Context, err error) error {
+			return nil
+		},
+	})
+
 	// Post routes
 	apiGroup.GET("/posts", postHandler.ListPosts) // Publicly accessible list of posts
-	apiGroup.GET("/posts/:id", postHandler.GetPost)
+	apiGroup.GET("/posts/:id", postHandler.GetPost, optionalAuth)
 	apiGroup.GET("/posts/search", postHandler.SearchPosts)
+	apiGroup.GET("/posts/:id/raw", postHandler.GetPostMarkdown, optionalAuth)
+	apiGroup.GET("/posts/:id/history", postHandler.GetPostHistory, optionalAuth)
+	apiGroup.GET("/posts/:id/versions/:version", postHandler.GetPostVersion, optionalAuth)
+	apiGroup.GET("/posts/:id/diff", postHandler.DiffPostVersions, optionalAuth)
 
 	// Authenticated routes
 	authGroup := apiGroup.Group("")
 	authGroup.Use(echojwt.WithConfig(echojwt.Config{
 		SigningKey: []byte(cfg.JWTSecret),
 	}))
+	authGroup.GET("/posts/mine", postHandler.ListMyPosts)
 	authGroup.POST("/posts", postHandler.CreatePost)
+	authGroup.PUT("/posts/:id/status", postHandler.SetPostStatus)
 	authGroup.PUT("/posts/:id", postHandler.UpdatePost)
 	authGroup.POST("/posts/upload", postHandler.CreateFromUpload)
+	authGroup.POST("/posts/:id/versions/:version/restore", postHandler.RestorePostVersion)
+	authGroup.DELETE("/posts/:id", postHandler.DeletePost)
+	authGroup.GET("/trash", postHandler.ListTrash)
+	authGroup.POST("/trash/:id/restore", postHandler.UndeletePost)
+	authGroup.POST("/media", mediaHandler.UploadMedia)
 }
\ No newline at end of file
@@ -3,82 +3,124 @@ package api
 import (
 	"errors"
 	"go-blog/internal/config"
+	"go-blog/internal/diff"
 	"go-blog/internal/middleware" // Added this import
+	"go-blog/internal/model"
 	"go-blog/internal/service"
+	"go-blog/internal/web"
 	"html/template"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 	"log" // Added log import
 
 	"github.com/golang-jwt/jwt/v5" // Ensured this import is present
 	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 	"github.com/labstack/echo/v4"
 )
 
 // WebHandler handles requests for server-side rendered pages.
 type WebHandler struct {
-	cfg         *config.Config
-	postService service.PostService
-	userService service.UserService
+	cfg          *config.Config
+	postService  service.PostService
+	userService  service.UserService
+	mediaService service.MediaService
 }
 
 // NewWebHandler creates a new WebHandler.
-func NewWebHandler(cfg *config.Config, ps service.PostService, us service.UserService) *WebHandler {
-	return &WebHandler{cfg: cfg, postService: ps, userService: us}
+func NewWebHandler(cfg *config.Config, ps service.PostService, us service.UserService, ms service.MediaService) *WebHandler {
+	return &WebHandler{cfg: cfg, postService: ps, userService: us, mediaService: ms}
 }
 
-// RenderIndexPage renders the home page with a list of all posts.
+// RenderIndexPage renders the home page with a list of published posts.
 func (h *WebHandler) RenderIndexPage(c echo.Context) error {
-	page, err := strconv.Atoi(c.QueryParam("page"))
-	if err != nil || page < 1 {
-		page = 1
-	}
+	return h.renderPostList(c, model.PostFilter{}, "/", "")
+}
 
-	limit, err := strconv.Atoi(c.QueryParam("limit"))
-	if err != nil || limit < 1 {
-		limit = 10 // Default limit
+// RenderTagPage renders the published posts with a tag.
+func (h *WebHandler) RenderTagPage(c echo.Context) error {
+	tag := pathParam(c, "tag")
+	return h.renderPostList(c, model.PostFilter{Tags: []string{tag}}, "/tags/"+url.PathEscape(tag), "#"+tag)
+}
+
+// RenderAuthorPage renders the published posts of a user.
+func (h *WebHandler) RenderAuthorPage(c echo.Context) error {
+	username := pathParam(c, "username")
+	user, err := h.userService.GetByUsername(username)
+	if err != nil {
+		return echo.ErrNotFound
 	}
+	return h.renderPostList(c, model.PostFilter{UserID: user.ID}, "/authors/"+url.PathEscape(username), user.Username)
+}
+
+// renderPostList renders a page of the posts filter selects. basePath is
+// the URL the pager links to and heading replaces the site name, if set.
+func (h *WebHandler) renderPostList(c echo.Context, filter model.PostFilter, basePath, heading string) error {
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
 
-	posts, err := h.postService.List(page, limit)
+	page, err := h.postService.List(filter, c.QueryParam("cursor"), limit, false)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return c.Redirect(http.StatusFound, basePath)
+		}
 		log.Printf("Error fetching posts: %v", err)
 		return c.String(http.StatusInternalServerError, "Could not fetch posts")
 	}
 
 	return c.Render(http.StatusOK, "index.html", map[string]interface{}{
-		"User":    c.Get(middleware.UserContextKey),
-		"Context": c,
-		"Posts":   posts,
+		"User":       c.Get(middleware.UserContextKey),
+		"Context":    c,
+		"Heading":    heading,
+		"BasePath":   basePath,
+		"Posts":      page.Items,
+		"NextCursor": page.NextCursor,
+		"PrevCursor": page.PrevCursor,
 	})
 }
 
+// pathParam returns a route parameter with any percent-encoding undone.
+func pathParam(c echo.Context, name string) string {
+	v := c.Param(name)
+	if unescaped, err := url.PathUnescape(v); err == nil {
+		return unescaped
+	}
+	return v
+}
+
 // RenderPostPage renders the page for a single post.
 func (h *WebHandler) RenderPostPage(c echo.Context) error {
-	id, err := strconv.Atoi(c.Param("id"))
+	id, redirect, err := resolvePost(c, h.postService)
 	if err != nil {
-		return c.String(http.StatusBadRequest, "Invalid post ID")
+		return echo.ErrNotFound
+	}
+	if redirect != "" {
+		return c.Redirect(http.StatusMovedPermanently, redirect)
 	}
 
-	post, mdContent, err := h.postService.GetByID(id)
+	post, mdContent, err := h.postService.GetByID(id, viewerID(c))
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
-			// Use the localizer to return a translated "not found" message.
-			// This requires a bit more setup in the handler or a custom error handler.
-			// For now, we keep it simple.
-			return c.String(http.StatusNotFound, "post_not_found")
+			return echo.ErrNotFound
 		}
-		return c.String(http.StatusNotFound, "post_not_found")
+		log.Printf("Error loading post %d: %v", id, err)
+		return c.String(http.StatusInternalServerError, "Could not load post")
+	}
+	// Links by ID predate slugs; point search engines at the slug instead.
+	if post.Slug != "" && c.Param("id") != post.Slug {
+		return c.Redirect(http.StatusMovedPermanently, withPostParam(c, post.Slug))
 	}
 
 	// Convert markdown to HTML
 	extensions := parser.CommonExtensions | parser.AutoHeadingIDs
 	p := parser.NewWithExtensions(extensions)
-	htmlContent := markdown.ToHTML([]byte(mdContent), p, nil)
-	// Use html.Renderer to get more control if needed in the future.
-	// renderer := html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags})
-	// htmlContent = markdown.ToHTML([]byte(mdContent), p, renderer)
+	doc := p.Parse([]byte(mdContent))
+	// Let browsers pick a resized variant of uploaded images.
+	web.AddImageSrcset(doc, h.mediaService.GetByID)
+	renderer := html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags | html.LazyLoadImages})
+	htmlContent := markdown.Render(doc, renderer)
 	
 	// log.Printf("web handler ", c.Get(middleware.UserContextKey))
 	
@@ -90,6 +132,47 @@ func (h *WebHandler) RenderPostPage(c echo.Context) error {
 	})
 }
 
+// RenderDiffPage renders a side-by-side comparison of two versions of a post.
+func (h *WebHandler) RenderDiffPage(c echo.Context) error {
+	id, redirect, err := resolvePost(c, h.postService)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+	if redirect != "" {
+		return c.Redirect(http.StatusMovedPermanently, redirect)
+	}
+	from, err := strconv.Atoi(c.QueryParam("from"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid version")
+	}
+	to, err := strconv.Atoi(c.QueryParam("to"))
+	if err != nil {
+		return c.String(http.StatusBadRequest, "Invalid version")
+	}
+
+	post, _, err := h.postService.GetByID(id, viewerID(c))
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	postDiff, err := h.postService.Diff(id, from, to, viewerID(c))
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			return echo.ErrNotFound
+		}
+		log.Printf("Error diffing post %d: %v", id, err)
+		return c.String(http.StatusInternalServerError, "Could not compare versions")
+	}
+
+	return c.Render(http.StatusOK, "diff.html", map[string]interface{}{
+		"User":    c.Get(middleware.UserContextKey),
+		"Context": c,
+		"Post":    post,
+		"Diff":    postDiff,
+		"Rows":    diff.SideBySide(postDiff.Hunks),
+	})
+}
+
 // RenderLoginPage renders the login page.
 func (h *WebHandler) RenderLoginPage(c echo.Context) error {
 	return c.Render(http.StatusOK, "login.html", nil)
@@ -2,6 +2,7 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -11,12 +12,70 @@ type Config struct {
 	ServerPort   string `mapstructure:"SERVER_PORT"`
 	DatabaseURL  string `mapstructure:"DATABASE_URL"`
 	JWTSecret    string `mapstructure:"JWT_SECRET"`
-	StorageType  string `mapstructure:"STORAGE_TYPE"` // "local" or "s3"
+	StorageType  string `mapstructure:"STORAGE_TYPE"` // "local", "s3" or "memory"
 	S3Bucket     string `mapstructure:"S3_BUCKET"`
 	S3Region     string `mapstructure:"S3_REGION"`
+	// How users' files are kept apart: "shared", "prefix" or "bucket".
+	// With "bucket", S3_BUCKET is ignored and each user gets the bucket
+	// named by S3_BUCKET_PATTERN, which must contain a single %d.
+	StorageIsolation string `mapstructure:"STORAGE_ISOLATION"`
+	S3BucketPattern  string `mapstructure:"S3_BUCKET_PATTERN"`
+	// Lifetime of the signed URLs media and markdown downloads redirect to.
+	// Local storage signs them with FILE_URL_SECRET, and streams files
+	// through the app when that is empty.
+	StorageURLTTL time.Duration `mapstructure:"STORAGE_URL_TTL"`
+	FileURLSecret string        `mapstructure:"FILE_URL_SECRET"`
+	// Read cache for stored files: STORAGE_CACHE_SIZE bytes in memory, plus
+	// up to STORAGE_CACHE_DISK_SIZE bytes in STORAGE_CACHE_DIR if that is set.
+	// STORAGE_CACHE_DIR is emptied at startup.
+	StorageCacheSize     int64  `mapstructure:"STORAGE_CACHE_SIZE"`
+	StorageCacheDir      string `mapstructure:"STORAGE_CACHE_DIR"`
+	StorageCacheDiskSize int64  `mapstructure:"STORAGE_CACHE_DISK_SIZE"`
 	AWSAccessKey string `mapstructure:"AWS_ACCESS_KEY_ID"`
 	AWSSecretKey string `mapstructure:"AWS_SECRET_ACCESS_KEY"`
+	// S3-compatible servers such as MinIO. Leave unset for AWS.
+	S3Endpoint           string `mapstructure:"S3_ENDPOINT"`
+	// Address browsers reach S3_ENDPOINT at, when it differs; presigned
+	// URLs are signed for it.
+	S3PublicEndpoint     string `mapstructure:"S3_PUBLIC_ENDPOINT"`
+	S3ForcePathStyle     bool   `mapstructure:"S3_FORCE_PATH_STYLE"`
+	S3DisableSSL         bool   `mapstructure:"S3_DISABLE_SSL"`
+	S3InsecureSkipVerify bool   `mapstructure:"S3_INSECURE_SKIP_VERIFY"`
+	// Server-side encryption of uploaded objects: "AES256" (SSE-S3) or
+	// "aws:kms" (SSE-KMS, with S3_SSE_KMS_KEY_ID or the default key).
+	S3SSE         string `mapstructure:"S3_SSE"`
+	S3SSEKMSKeyID string `mapstructure:"S3_SSE_KMS_KEY_ID"`
+	// Client-side envelope encryption of every stored file, for any storage
+	// type. A comma-separated list of id:base64 AES-256 keys; the first one
+	// encrypts new files and the rest are kept to read older ones.
+	StorageEncryptionKeys string `mapstructure:"STORAGE_ENCRYPTION_KEYS"`
+	// Compression of stored text files such as post markdown: "" or "gzip".
+	StorageCompression string `mapstructure:"STORAGE_COMPRESSION"`
 	TokenExpiresInHours int `mapstructure:"TOKEN_EXPIRES_IN_HOURS"`
+
+	// How post content files are named: "versioned" writes one file per
+	// version, "cas" stores each distinct content once under its SHA-256.
+	ContentLayout string `mapstructure:"CONTENT_LAYOUT"`
+
+	// Largest accepted media upload, in bytes.
+	MediaMaxSize int64 `mapstructure:"MEDIA_MAX_SIZE"`
+
+	// Orphaned content file garbage collection. The background sweeper is
+	// disabled when the interval is zero, and only reports orphans unless
+	// CONTENT_GC_DELETE is set.
+	ContentGCInterval    time.Duration `mapstructure:"CONTENT_GC_INTERVAL"`
+	ContentGCGracePeriod time.Duration `mapstructure:"CONTENT_GC_GRACE_PERIOD"`
+	ContentGCDelete      bool          `mapstructure:"CONTENT_GC_DELETE"`
+
+	// Deleted posts are kept in the trash for TRASH_RETENTION before the
+	// purge job removes them for good. The job is disabled when
+	// TRASH_PURGE_INTERVAL is zero.
+	TrashRetention     time.Duration `mapstructure:"TRASH_RETENTION"`
+	TrashPurgeInterval time.Duration `mapstructure:"TRASH_PURGE_INTERVAL"`
+
+	// How often scheduled posts that are due are marked published. The
+	// scheduler is disabled when the interval is zero.
+	SchedulerInterval time.Duration `mapstructure:"SCHEDULER_INTERVAL"`
 }
 
 // Load reads configuration from environment variables.
@@ -33,8 +92,36 @@ func Load() (*Config, error) {
 
 	viper.SetDefault("SERVER_PORT", "8080")
 	viper.SetDefault("STORAGE_TYPE", "local")
+	viper.SetDefault("STORAGE_ISOLATION", "shared")
+	viper.SetDefault("S3_BUCKET_PATTERN", "go-blog-user-%d")
+	viper.SetDefault("STORAGE_URL_TTL", "15m")
+	viper.SetDefault("FILE_URL_SECRET", "")
+	viper.SetDefault("STORAGE_CACHE_SIZE", 64<<20) // 64 MiB
+	viper.SetDefault("STORAGE_CACHE_DIR", "")
+	viper.SetDefault("STORAGE_CACHE_DISK_SIZE", 1<<30) // 1 GiB
+	// Keys without a default are invisible to Unmarshal when they only
+	// come from the environment, so register the S3 settings explicitly.
+	viper.SetDefault("S3_ENDPOINT", "")
+	viper.SetDefault("S3_PUBLIC_ENDPOINT", "")
+	viper.SetDefault("AWS_ACCESS_KEY_ID", "")
+	viper.SetDefault("AWS_SECRET_ACCESS_KEY", "")
+	viper.SetDefault("S3_FORCE_PATH_STYLE", false)
+	viper.SetDefault("S3_DISABLE_SSL", false)
+	viper.SetDefault("S3_INSECURE_SKIP_VERIFY", false)
+	viper.SetDefault("S3_SSE", "")
+	viper.SetDefault("S3_SSE_KMS_KEY_ID", "")
+	viper.SetDefault("STORAGE_ENCRYPTION_KEYS", "")
+	viper.SetDefault("STORAGE_COMPRESSION", "")
 	viper.SetDefault("JWT_SECRET", "a-very-secret-key-that-should-be-changed")
     viper.SetDefault("TOKEN_EXPIRES_IN_HOURS", 72)
+	viper.SetDefault("CONTENT_LAYOUT", "versioned")
+	viper.SetDefault("MEDIA_MAX_SIZE", 10<<20) // 10 MiB
+	viper.SetDefault("CONTENT_GC_INTERVAL", "0")
+	viper.SetDefault("CONTENT_GC_GRACE_PERIOD", "24h")
+	viper.SetDefault("CONTENT_GC_DELETE", false)
+	viper.SetDefault("TRASH_RETENTION", "720h") // 30 days
+	viper.SetDefault("TRASH_PURGE_INTERVAL", "1h")
+	viper.SetDefault("SCHEDULER_INTERVAL", "1m")
 
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -0,0 +1,367 @@
+// Package diff computes line-level differences between two texts.
+//
+// It implements the Myers O(ND) algorithm, which is the same algorithm
+// behind `diff` and `git diff`, and groups the resulting edits into hunks
+// with surrounding context lines. Texts too far apart for the search to be
+// cheap get a single hunk replacing everything that differs.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op describes what happened to a single line.
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Line is a single line of a hunk. OldLine and NewLine are 1-based line
+// numbers in the old and new text; a zero value means the line does not
+// exist on that side. NoNewline marks the last line of a text that doesn't
+// end with a newline.
+type Line struct {
+	Op        Op     `json:"op"`
+	Text      string `json:"text"`
+	OldLine   int    `json:"old_line,omitempty"`
+	NewLine   int    `json:"new_line,omitempty"`
+	NoNewline bool   `json:"no_newline,omitempty"`
+}
+
+// Hunk is a group of changed lines plus their context, using the same
+// ranges as a unified diff "@@ -a,b +c,d @@" header.
+type Hunk struct {
+	OldStart int    `json:"old_start"`
+	OldLines int    `json:"old_lines"`
+	NewStart int    `json:"new_start"`
+	NewLines int    `json:"new_lines"`
+	Lines    []Line `json:"lines"`
+}
+
+// DefaultContext is the number of unchanged lines kept around each change.
+const DefaultContext = 3
+
+// MaxEdits caps the number of inserted and deleted lines Compute searches
+// for. The search keeps a snapshot of its state per edit, so its memory
+// grows with the square of that number; texts further apart are shown as
+// one change from their first to their last differing line.
+const MaxEdits = 1000
+
+// Compute returns the hunks that turn a into b. Identical inputs produce no hunks.
+func Compute(a, b string, context int) []Hunk {
+	return group(edits(splitLines(a), splitLines(b)), context)
+}
+
+// Unified renders hunks in the unified diff format.
+func Unified(fromName, toName string, hunks []Hunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			switch l.Op {
+			case Insert:
+				sb.WriteByte('+')
+			case Delete:
+				sb.WriteByte('-')
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(l.Text)
+			sb.WriteByte('\n')
+			if l.NoNewline {
+				sb.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Row is one row of a side-by-side view. Either side may be nil when a line
+// only exists in one of the two texts.
+type Row struct {
+	Old *Line
+	New *Line
+}
+
+// SideBySide lays hunks out as rows for a two-column view. Runs of deleted
+// lines are paired with the inserted lines that follow them, so a modified
+// line shows up next to its replacement.
+func SideBySide(hunks []Hunk) [][]Row {
+	out := make([][]Row, 0, len(hunks))
+	for _, h := range hunks {
+		var rows []Row
+		for i := 0; i < len(h.Lines); {
+			if h.Lines[i].Op == Equal {
+				rows = append(rows, Row{Old: &h.Lines[i], New: &h.Lines[i]})
+				i++
+				continue
+			}
+
+			var dels, ins []*Line
+			for ; i < len(h.Lines) && h.Lines[i].Op == Delete; i++ {
+				dels = append(dels, &h.Lines[i])
+			}
+			for ; i < len(h.Lines) && h.Lines[i].Op == Insert; i++ {
+				ins = append(ins, &h.Lines[i])
+			}
+			for j := 0; j < len(dels) || j < len(ins); j++ {
+				var row Row
+				if j < len(dels) {
+					row.Old = dels[j]
+				}
+				if j < len(ins) {
+					row.New = ins[j]
+				}
+				rows = append(rows, row)
+			}
+		}
+		out = append(out, rows)
+	}
+	return out
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// textLine is a line of an input text. The last line of a text without a
+// trailing newline differs from the same line with one.
+type textLine struct {
+	text      string
+	noNewline bool
+}
+
+func splitLines(s string) []textLine {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	noNewline := !strings.HasSuffix(s, "\n")
+	parts := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	lines := make([]textLine, len(parts))
+	for i, p := range parts {
+		lines[i] = textLine{text: p}
+	}
+	lines[len(lines)-1].noNewline = noNewline
+	return lines
+}
+
+// edits returns every line of both inputs annotated with its operation, in
+// order. Lines the inputs start and end with are equal whatever lies
+// between, so only the rest is searched.
+func edits(a, b []textLine) []Line {
+	n, m := len(a), len(b)
+	prefix := 0
+	for prefix < n && prefix < m && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < n-prefix && suffix < m-prefix && a[n-1-suffix] == b[m-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, n+m-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, equalLine(a[i], i+1, i+1))
+	}
+	oldMid, newMid := a[prefix:n-suffix], b[prefix:m-suffix]
+	middle, ok := myers(oldMid, newMid)
+	if !ok {
+		middle = replaceAll(oldMid, newMid)
+	}
+	for _, l := range middle {
+		if l.OldLine > 0 {
+			l.OldLine += prefix
+		}
+		if l.NewLine > 0 {
+			l.NewLine += prefix
+		}
+		lines = append(lines, l)
+	}
+	for i := suffix; i > 0; i-- {
+		lines = append(lines, equalLine(a[n-i], n-i+1, m-i+1))
+	}
+	return lines
+}
+
+func equalLine(l textLine, oldLine, newLine int) Line {
+	return Line{Op: Equal, Text: l.text, OldLine: oldLine, NewLine: newLine, NoNewline: l.noNewline}
+}
+
+// replaceAll is the edit script that deletes all of a and inserts all of b.
+func replaceAll(a, b []textLine) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for i, l := range a {
+		lines = append(lines, Line{Op: Delete, Text: l.text, OldLine: i + 1, NoNewline: l.noNewline})
+	}
+	for i, l := range b {
+		lines = append(lines, Line{Op: Insert, Text: l.text, NewLine: i + 1, NoNewline: l.noNewline})
+	}
+	return lines
+}
+
+// myers runs the Myers shortest edit script search. It gives up, reporting
+// false, once the script would be longer than MaxEdits.
+func myers(a, b []textLine) ([]Line, bool) {
+	n, m := len(a), len(b)
+	total := n + m
+	if total == 0 {
+		return nil, true
+	}
+	maxD := min(total, MaxEdits)
+
+	// v[offset+k] holds the furthest x reached on diagonal k. Before each
+	// round the live part of v is snapshotted so the path can be rebuilt.
+	offset := maxD
+	v := make([]int, 2*maxD+2)
+	var trace [][]int
+
+search:
+	for d := 0; ; d++ {
+		if d > maxD {
+			return nil, false
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the path.
+	var lines []Line
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		snap := trace[d] // snap[d+k] is the x reached on diagonal k after round d-1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && snap[d+k-1] < snap[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = snap[d+prevK]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			lines = append(lines, equalLine(a[x-1], x, y))
+			x--
+			y--
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			lines = append(lines, Line{Op: Insert, Text: b[y-1].text, NewLine: y, NoNewline: b[y-1].noNewline})
+		} else {
+			lines = append(lines, Line{Op: Delete, Text: a[x-1].text, OldLine: x, NoNewline: a[x-1].noNewline})
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines, true
+}
+
+// group splits an edit script into hunks, keeping up to context unchanged
+// lines around each change and merging changes that are close together.
+func group(lines []Line, context int) []Hunk {
+	if context < 0 {
+		context = 0
+	}
+
+	var hunks []Hunk
+	oldSeen, newSeen := 0, 0 // lines of each side consumed before index i
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			oldSeen++
+			newSeen++
+			i++
+			continue
+		}
+
+		lead := min(context, i)
+		start := i - lead
+
+		// Extend the hunk while the next change is within 2*context lines.
+		end := i
+		for end < len(lines) {
+			if lines[end].Op != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Op == Equal {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		h := Hunk{Lines: lines[start:end]}
+		for _, l := range h.Lines {
+			if l.Op != Insert {
+				h.OldLines++
+			}
+			if l.Op != Delete {
+				h.NewLines++
+			}
+		}
+		// An empty range is anchored on the line before it, as in GNU diff.
+		h.OldStart = oldSeen - lead
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		h.NewStart = newSeen - lead
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+
+		for _, l := range lines[i:end] {
+			if l.Op != Insert {
+				oldSeen++
+			}
+			if l.Op != Delete {
+				newSeen++
+			}
+		}
+		i = end
+	}
+	return hunks
+}
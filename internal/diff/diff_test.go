@@ -0,0 +1,107 @@
+package diff_test
+
+import (
+	"fmt"
+	"go-blog/internal/diff"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompute_Identical(t *testing.T) {
+	assert.Empty(t, diff.Compute("a\nb\nc\n", "a\nb\nc\n", diff.DefaultContext))
+}
+
+func TestUnified(t *testing.T) {
+	from := "# Title\n\nfirst paragraph\nsecond paragraph\n\nfooter\n"
+	to := "# Better Title\n\nfirst paragraph\nsecond paragraph\nthird paragraph\n\nfooter\n"
+
+	hunks := diff.Compute(from, to, 1)
+	expected := strings.Join([]string{
+		"--- v1",
+		"+++ v2",
+		"@@ -1,2 +1,2 @@",
+		"-# Title",
+		"+# Better Title",
+		" ",
+		"@@ -4,2 +4,3 @@",
+		" second paragraph",
+		"+third paragraph",
+		" ",
+		"",
+	}, "\n")
+
+	assert.Equal(t, expected, diff.Unified("v1", "v2", hunks))
+}
+
+func TestCompute_EmptySides(t *testing.T) {
+	hunks := diff.Compute("", "one\ntwo\n", diff.DefaultContext)
+	assert.Len(t, hunks, 1)
+	assert.Equal(t, 0, hunks[0].OldStart)
+	assert.Equal(t, 0, hunks[0].OldLines)
+	assert.Equal(t, 1, hunks[0].NewStart)
+	assert.Equal(t, 2, hunks[0].NewLines)
+
+	hunks = diff.Compute("one\ntwo\n", "", diff.DefaultContext)
+	assert.Len(t, hunks, 1)
+	assert.Equal(t, 2, hunks[0].OldLines)
+	assert.Equal(t, 0, hunks[0].NewStart)
+}
+
+func TestSideBySide(t *testing.T) {
+	hunks := diff.Compute("a\nb\nc\n", "a\nB\nc\nd\n", diff.DefaultContext)
+	rows := diff.SideBySide(hunks)
+
+	assert.Len(t, rows, 1)
+	assert.Len(t, rows[0], 4)
+
+	// The modified line is paired with its replacement.
+	assert.Equal(t, "b", rows[0][1].Old.Text)
+	assert.Equal(t, "B", rows[0][1].New.Text)
+
+	// The appended line has nothing on the old side.
+	assert.Nil(t, rows[0][3].Old)
+	assert.Equal(t, "d", rows[0][3].New.Text)
+}
+
+func TestCompute_TooManyEdits(t *testing.T) {
+	var from, to strings.Builder
+	from.WriteString("same start\n")
+	to.WriteString("same start\n")
+	for i := 0; i < 8000; i++ {
+		fmt.Fprintf(&from, "old %d\n", i)
+		fmt.Fprintf(&to, "new %d\n", i)
+	}
+	from.WriteString("same end\n")
+	to.WriteString("same end\n")
+
+	// Past MaxEdits everything between the common start and end is
+	// replaced in one hunk instead of searching for the shortest script.
+	hunks := diff.Compute(from.String(), to.String(), 1)
+	assert.Len(t, hunks, 1)
+	h := hunks[0]
+	assert.Equal(t, 1, h.OldStart)
+	assert.Equal(t, 8002, h.OldLines)
+	assert.Equal(t, 8002, h.NewLines)
+	assert.Equal(t, diff.Line{Op: diff.Equal, Text: "same start", OldLine: 1, NewLine: 1}, h.Lines[0])
+	assert.Equal(t, diff.Line{Op: diff.Delete, Text: "old 0", OldLine: 2}, h.Lines[1])
+	assert.Equal(t, diff.Line{Op: diff.Insert, Text: "new 0", NewLine: 2}, h.Lines[8001])
+	assert.Equal(t, diff.Line{Op: diff.Equal, Text: "same end", OldLine: 8002, NewLine: 8002}, h.Lines[16001])
+}
+
+func TestUnified_NoNewlineAtEnd(t *testing.T) {
+	hunks := diff.Compute("a\nb\n", "a\nb", diff.DefaultContext)
+	expected := strings.Join([]string{
+		"--- v1",
+		"+++ v2",
+		"@@ -1,2 +1,2 @@",
+		" a",
+		"-b",
+		"+b",
+		`\ No newline at end of file`,
+		"",
+	}, "\n")
+
+	assert.Equal(t, expected, diff.Unified("v1", "v2", hunks))
+}
@@ -0,0 +1,186 @@
+// Package imaging decodes uploaded images and produces the resized
+// variants served to browsers.
+//
+// Only the standard library codecs are used, so JPEG, PNG and GIF can be
+// decoded. Variants are always written as baseline JPEG: re-encoding drops
+// every metadata segment of the original, including EXIF and GPS data.
+// Originals are kept as uploaded, less their metadata; see StripMetadata.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	// Register the decoders used by image.Decode.
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Variant is a named width that uploaded images are resized to.
+type Variant struct {
+	Name  string
+	Width int
+}
+
+// Variants are generated for every uploaded image, smallest first. A variant
+// is skipped when the original is not wider than it; images are never upscaled.
+var Variants = []Variant{
+	{Name: "thumb", Width: 320},
+	{Name: "medium", Width: 800},
+	{Name: "large", Width: 1600},
+}
+
+// LookupVariant returns the variant called name.
+func LookupVariant(name string) (Variant, bool) {
+	for _, v := range Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}
+
+// MaxPixels bounds the size of images Decode accepts. A small, highly
+// compressed file can otherwise claim dimensions that need gigabytes of
+// memory to decode.
+const MaxPixels = 50_000_000
+
+// ErrTooManyPixels is returned by Decode for images larger than MaxPixels.
+var ErrTooManyPixels = errors.New("image dimensions too large")
+
+// JPEGQuality is the quality variants are encoded with.
+const JPEGQuality = 82
+
+// Decode decodes an image and rotates it upright according to its EXIF
+// orientation, since that tag is lost when the image is re-encoded.
+func Decode(data []byte) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return orient(img, exifOrientation(data)), nil
+}
+
+// Fit scales img down to width, keeping its aspect ratio. Images that are
+// already narrow enough are returned unchanged.
+func Fit(img image.Image, width int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= width {
+		return img
+	}
+	height := (b.Dy()*width + b.Dx()/2) / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	return resample(toRGBA(img), width, height)
+}
+
+// EncodeJPEG writes img as a JPEG. Transparent areas are flattened onto
+// white, since JPEG has no alpha channel and would otherwise turn them black.
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, b.Min, draw.Over)
+	return jpeg.Encode(w, flat, &jpeg.Options{Quality: JPEGQuality})
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
+
+// contribution is the share of source pixel index in one destination pixel.
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// areaWeights maps every destination pixel to the source pixels it covers,
+// weighted by how much of each one it covers. This is a box filter, which
+// gives clean results for the downscaling Fit does.
+func areaWeights(srcLen, dstLen int) [][]contribution {
+	scale := float64(srcLen) / float64(dstLen)
+	out := make([][]contribution, dstLen)
+	for i := range out {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < srcLen && float64(j) < end; j++ {
+			lo, hi := max(start, float64(j)), min(end, float64(j+1))
+			out[i] = append(out[i], contribution{index: j, weight: (hi - lo) / scale})
+		}
+	}
+	return out
+}
+
+// resample scales src to width x height, filtering horizontally and then
+// vertically. src holds premultiplied colors, so averaging is correct at
+// transparent edges.
+func resample(src *image.RGBA, width, height int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	xw, yw := areaWeights(sw, width), areaWeights(sh, height)
+
+	// Horizontal pass into a float buffer of width x sh.
+	tmp := make([]float64, width*sh*4)
+	for y := 0; y < sh; y++ {
+		row := src.Pix[y*src.Stride:]
+		for x, cs := range xw {
+			var r, g, b, a float64
+			for _, c := range cs {
+				p := row[c.index*4:]
+				r += float64(p[0]) * c.weight
+				g += float64(p[1]) * c.weight
+				b += float64(p[2]) * c.weight
+				a += float64(p[3]) * c.weight
+			}
+			t := tmp[(y*width+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, b, a
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, cs := range yw {
+		row := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			var r, g, b, a float64
+			for _, c := range cs {
+				t := tmp[(c.index*width+x)*4:]
+				r += t[0] * c.weight
+				g += t[1] * c.weight
+				b += t[2] * c.weight
+				a += t[3] * c.weight
+			}
+			p := row[x*4:]
+			p[0], p[1], p[2], p[3] = clamp(r), clamp(g), clamp(b), clamp(a)
+		}
+	}
+	return dst
+}
+
+func clamp(v float64) uint8 {
+	v += 0.5
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
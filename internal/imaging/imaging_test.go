@@ -0,0 +1,257 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestFit(t *testing.T) {
+	img := solid(1000, 500, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+
+	out := Fit(img, 320)
+	assert.Equal(t, 320, out.Bounds().Dx())
+	assert.Equal(t, 160, out.Bounds().Dy())
+	// A flat color stays the same color after averaging.
+	assert.Equal(t, color.RGBA{R: 200, G: 100, B: 50, A: 255}, out.At(100, 100))
+
+	// Never upscale.
+	assert.Same(t, img, Fit(img, 1600))
+}
+
+func TestEncodeJPEG_FlattensTransparency(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, EncodeJPEG(&buf, image.NewNRGBA(image.Rect(0, 0, 8, 8))))
+
+	out, err := jpeg.Decode(&buf)
+	require.NoError(t, err)
+	r, g, b, _ := out.At(4, 4).RGBA()
+	assert.Greater(t, r>>8, uint32(250))
+	assert.Greater(t, g>>8, uint32(250))
+	assert.Greater(t, b>>8, uint32(250))
+}
+
+// withOrientation returns a JPEG carrying an EXIF APP1 segment that sets
+// the given orientation.
+func withOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	data := buf.Bytes()
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1) // one IFD entry
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // padding and next IFD offset
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func TestDecode_AppliesOrientation(t *testing.T) {
+	data := withOrientation(t, solid(40, 20, color.Black), 6)
+	assert.Equal(t, 6, exifOrientation(data))
+
+	img, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, 20, img.Bounds().Dx())
+	assert.Equal(t, 40, img.Bounds().Dy())
+}
+
+func TestOrient(t *testing.T) {
+	// A 2x1 image: red on the left, blue on the right.
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	src.Set(0, 0, red)
+	src.Set(1, 0, blue)
+
+	rotated := orient(src, 6) // rotate 90 clockwise: red ends up on top
+	assert.Equal(t, image.Rect(0, 0, 1, 2), rotated.Bounds())
+	assert.Equal(t, red, rotated.At(0, 0))
+	assert.Equal(t, blue, rotated.At(0, 1))
+
+	mirrored := orient(src, 2)
+	assert.Equal(t, blue, mirrored.At(0, 0))
+}
+
+func TestDecode_RejectsHugeDimensions(t *testing.T) {
+	// A PNG header claiming 100000x100000 pixels.
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	_, err := Decode(data)
+	assert.ErrorIs(t, err, ErrTooManyPixels)
+}
+
+// insertAfterSOI inserts JPEG segments right after the start of image marker.
+func insertAfterSOI(data []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, data[2:]...)
+}
+
+func jpegSegment(marker byte, payload string) []byte {
+	s := []byte{0xFF, marker}
+	s = binary.BigEndian.AppendUint16(s, uint16(len(payload)+2))
+	return append(s, payload...)
+}
+
+func TestStripMetadata_JPEG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, solid(16, 8, color.Black), nil))
+	clean := buf.Bytes()
+
+	data := insertAfterSOI(clean,
+		jpegSegment(0xE1, "Exif\x00\x00GPS 52.37N 4.89E"),
+		jpegSegment(0xE1, "http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"),
+		jpegSegment(0xED, "Photoshop 3.0\x00IPTC"),
+		jpegSegment(0xFE, "taken at home"),
+		jpegSegment(0xE2, "ICC_PROFILE\x00\x01\x01profile"),
+	)
+	// Trailing data, such as the second picture of an MPO.
+	data = append(data, insertAfterSOI(clean, jpegSegment(0xE1, "Exif\x00\x00GPS"))...)
+
+	out, err := StripMetadata(data, "image/jpeg")
+	require.NoError(t, err)
+	for _, leak := range []string{"GPS", "xmpmeta", "IPTC", "taken at home"} {
+		assert.NotContains(t, string(out), leak)
+	}
+	assert.Contains(t, string(out), "ICC_PROFILE")
+	assert.Equal(t, 1, exifOrientation(out))
+
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	require.NoError(t, err)
+	assert.Equal(t, 16, img.Bounds().Dx())
+}
+
+func TestStripMetadata_JPEGKeepsOrientation(t *testing.T) {
+	// Add GPS data to the end of the EXIF segment, after the orientation.
+	data := withOrientation(t, solid(40, 20, color.Black), 6)
+	size := binary.BigEndian.Uint16(data[4:])
+	gps := "GPS 52.37N 4.89E"
+	binary.BigEndian.PutUint16(data[4:], size+uint16(len(gps)))
+	data = append(append(append([]byte{}, data[:4+size]...), gps...), data[4+size:]...)
+	require.Equal(t, 6, exifOrientation(data))
+
+	out, err := StripMetadata(data, "image/jpeg")
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "GPS")
+	assert.Equal(t, 6, exifOrientation(out))
+
+	img, err := Decode(out)
+	require.NoError(t, err)
+	assert.Equal(t, 20, img.Bounds().Dx(), "still rotated upright")
+}
+
+func pngChunk(kind, payload string) []byte {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	c = append(c, kind+payload...)
+	return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE([]byte(kind+payload)))
+}
+
+func TestStripMetadata_PNG(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, solid(4, 4, color.White)))
+	clean := buf.Bytes()
+
+	// Metadata chunks go after IHDR, which is 8+25 bytes in.
+	var data []byte
+	data = append(data, clean[:33]...)
+	data = append(data, pngChunk("tEXt", "Comment\x00taken at home")...)
+	data = append(data, pngChunk("eXIf", "MM\x00\x2aGPS")...)
+	data = append(data, clean[33:]...)
+
+	out, err := StripMetadata(data, "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, clean, out)
+}
+
+func TestStripMetadata_GIF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White}), nil))
+	clean := buf.Bytes()
+
+	// The comment goes before the image descriptor, after the color table.
+	at := bytes.IndexByte(clean, 0x2C)
+	var data []byte
+	data = append(data, clean[:at]...)
+	data = append(data, 0x21, 0xFE, 13)
+	data = append(data, "taken at home"...)
+	data = append(data, 0)
+	data = append(data, 0x21, 0xFF, 11)
+	data = append(data, "XMP DataXMP"...)
+	data = append(data, 3, 'x', 'm', 'p', 0)
+	data = append(data, clean[at:]...)
+
+	out, err := StripMetadata(data, "image/gif")
+	require.NoError(t, err)
+	assert.Equal(t, clean, out)
+}
+
+func TestStripMetadata_WebP(t *testing.T) {
+	chunk := func(kind, payload string) []byte {
+		c := append([]byte(kind), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	riff := func(chunks ...[]byte) []byte {
+		body := []byte("WEBP")
+		for _, c := range chunks {
+			body = append(body, c...)
+		}
+		return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+	}
+
+	const flags = "\x0c\x00\x00\x00\x03\x00\x00\x03\x00\x00" // EXIF and XMP, 4x4
+	data := riff(chunk("VP8X", flags), chunk("VP8L", "pixels"), chunk("EXIF", "GPS"), chunk("XMP ", "<x:xmpmeta/>"))
+
+	out, err := StripMetadata(data, "image/webp")
+	require.NoError(t, err)
+	assert.Equal(t, riff(chunk("VP8X", "\x00"+flags[1:]), chunk("VP8L", "pixels")), out)
+}
+
+func TestStripMetadata_Malformed(t *testing.T) {
+	for contentType, data := range map[string]string{
+		"image/jpeg": "\xFF\xD8\xFF\xE1\x00",
+		"image/png":  "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR",
+		"image/gif":  "GIF89a\x04\x00\x04\x00\x00\x00\x00\x21\xFE\x05ab",
+		"image/webp": "RIFF\xff\x00\x00\x00WEBP",
+	} {
+		_, err := StripMetadata([]byte(data), contentType)
+		assert.ErrorIs(t, err, ErrMalformed, contentType)
+	}
+}
@@ -0,0 +1,284 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrMalformed is returned by StripMetadata for files it can't parse, so
+// it can't vouch for them being free of metadata.
+var ErrMalformed = errors.New("malformed image")
+
+// StripMetadata removes EXIF, XMP, IPTC, text and comment metadata from an
+// image of the given content type. The image data itself is copied as is,
+// so nothing is lost to re-encoding. A JPEG's EXIF orientation is kept, as
+// browsers need it to show the image upright, but nothing else of its EXIF.
+func StripMetadata(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/gif":
+		return stripGIF(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return nil, fmt.Errorf("%w: can't strip %s", ErrMalformed, contentType)
+	}
+}
+
+// stripJPEG copies the segments before the first scan that describe the
+// image, replaces EXIF with a bare orientation tag, and drops anything
+// after the end of the image, such as the extra pictures of an MPO.
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, ErrMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+
+	// The orientation goes right after the JFIF header, if there is one.
+	var orientation []byte
+	if o := exifOrientation(data); o != 1 {
+		orientation = orientationSegment(o)
+	}
+
+	for i := 2; ; {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, ErrMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// Fill byte before a marker.
+			i++
+			continue
+		}
+		if marker != 0xE0 && orientation != nil {
+			out = append(out, orientation...)
+			orientation = nil
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return nil, ErrMalformed
+		}
+		if marker == 0xDA {
+			// Start of scan: the rest is image data, in which 0xFF is
+			// always escaped, up to the end of image marker.
+			end := bytes.Index(data[i+2+size:], []byte{0xFF, 0xD9})
+			if end < 0 {
+				return nil, ErrMalformed
+			}
+			return append(out, data[i:i+2+size+end+2]...), nil
+		}
+		if keepJPEGSegment(marker, data[i+4:i+2+size]) {
+			out = append(out, data[i:i+2+size]...)
+		}
+		i += 2 + size
+	}
+}
+
+// keepJPEGSegment reports whether a segment is needed to show the image.
+// Application segments other than the JFIF header, color profiles and the
+// Adobe color transform only carry metadata, as do comments.
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xE0, marker == 0xEE:
+		return true
+	case marker == 0xE2:
+		return bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+	case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+		return false
+	default:
+		return true
+	}
+}
+
+// orientationSegment returns an EXIF APP1 segment holding nothing but the
+// orientation tag.
+func orientationSegment(orientation int) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1) // one IFD entry
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0) // padding and next IFD offset
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// pngMetadataChunks are the PNG chunks that only carry metadata.
+var pngMetadataChunks = map[string]bool{
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"eXIf": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, ErrMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+
+	for i := len(signature); ; {
+		if i+8 > len(data) {
+			return nil, ErrMalformed
+		}
+		size := int(binary.BigEndian.Uint32(data[i:]))
+		kind := string(data[i+4 : i+8])
+		end := i + 12 + size // length, type, data and CRC
+		if size < 0 || end > len(data) {
+			return nil, ErrMalformed
+		}
+		if !pngMetadataChunks[kind] {
+			out = append(out, data[i:end]...)
+		}
+		if kind == "IEND" {
+			return out, nil
+		}
+		i = end
+	}
+}
+
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, ErrMalformed
+	}
+	// Header and logical screen descriptor, then the global color table.
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, ErrMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:i]...)
+
+	for {
+		if i >= len(data) {
+			return nil, ErrMalformed
+		}
+		switch data[i] {
+		case 0x3B: // trailer
+			return append(out, 0x3B), nil
+		case 0x2C: // image descriptor, color table and image data
+			if i+10 > len(data) {
+				return nil, ErrMalformed
+			}
+			start := i
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&0x07 + 1)
+			}
+			end, err := skipSubBlocks(data, i+1) // after the LZW code size
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, data[start:end]...)
+			i = end
+		case 0x21: // extension
+			if i+2 > len(data) {
+				return nil, ErrMalformed
+			}
+			end, err := skipSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+			if keepGIFExtension(data[i+1], data[i+2:end]) {
+				out = append(out, data[i:end]...)
+			}
+			i = end
+		default:
+			return nil, ErrMalformed
+		}
+	}
+}
+
+// skipSubBlocks returns the offset after the data sub-blocks starting at i.
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, ErrMalformed
+		}
+		n := int(data[i])
+		i++
+		if n == 0 {
+			return i, nil
+		}
+		i += n
+	}
+}
+
+// keepGIFExtension reports whether an extension affects how the image is
+// shown: frame timing, plain text and the animation loop count. Comments
+// and other application extensions, such as XMP, are metadata.
+func keepGIFExtension(label byte, blocks []byte) bool {
+	switch label {
+	case 0xF9, 0x01:
+		return true
+	case 0xFF:
+		if len(blocks) < 12 || blocks[0] != 11 {
+			return false
+		}
+		id := string(blocks[1:12])
+		return id == "NETSCAPE2.0" || id == "ANIMEXTS1.0"
+	default:
+		return false
+	}
+}
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrMalformed
+	}
+	// Anything after the RIFF container is not part of the image.
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd > len(data) {
+		return nil, ErrMalformed
+	}
+	data = data[:riffEnd]
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, ErrMalformed
+		}
+		kind := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2 // chunks are padded to an even size
+		if size < 0 || end > len(data) {
+			return nil, ErrMalformed
+		}
+		switch kind {
+		case "EXIF", "XMP ":
+			// Metadata only.
+		case "VP8X":
+			chunk := append([]byte{}, data[i:end]...)
+			if size > 0 {
+				// Clear the flags announcing the chunks dropped above.
+				const exifFlag, xmpFlag = 0x08, 0x04
+				chunk[8] &^= exifFlag | xmpFlag
+			}
+			out = append(out, chunk...)
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"go-blog/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// SignedPathContextKey is the key for the verified storage path of a signed URL.
+const SignedPathContextKey = "signedPath"
+
+// SignedURL rejects requests whose URL was not signed by signer or whose
+// signature has expired. The storage path the URL points at is stored in
+// the context under SignedPathContextKey.
+func SignedURL(signer *storage.HMACSigner) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			path := strings.TrimPrefix(req.URL.Path, signer.Prefix())
+			query := req.URL.Query()
+
+			if err := signer.Verify(path, query.Get("expires"), query.Get("signature")); err != nil {
+				return echo.NewHTTPError(http.StatusForbidden, err.Error())
+			}
+
+			c.Set(SignedPathContextKey, path)
+			return next(c)
+		}
+	}
+}
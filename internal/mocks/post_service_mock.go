@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"go-blog/internal/model"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -27,39 +28,105 @@ func (m *PostService) Update(id int, title, content string, userID int) (*model.
 	return args.Get(0).(*model.Post), args.Error(1)
 }
 
-func (m *PostService) GetByID(id int) (*model.Post, string, error) {
-	args := m.Called(id)
+func (m *PostService) GetByID(id, viewerID int) (*model.Post, string, error) {
+	args := m.Called(id, viewerID)
 	if args.Get(0) == nil {
 		return nil, "", args.Error(2)
 	}
 	return args.Get(0).(*model.Post), args.String(1), args.Error(2)
 }
 
-func (m *PostService) List(page, limit int) ([]*model.Post, error) {
-	args := m.Called(page, limit)
+func (m *PostService) List(filter model.PostFilter, cursor string, limit int, withTotal bool) (*model.PostPage, error) {
+	args := m.Called(filter, cursor, limit, withTotal)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
-	return args.Get(0).([]*model.Post), args.Error(1)
+	return args.Get(0).(*model.PostPage), args.Error(1)
 }
 
-func (m *PostService) Search(query string, page, limit int) ([]*model.Post, error) {
-	args := m.Called(query, page, limit)
+func (m *PostService) ListOwn(userID, page, limit int) ([]*model.Post, error) {
+	args := m.Called(userID, page, limit)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*model.Post), args.Error(1)
 }
 
+func (m *PostService) ResolveSlug(name string, viewerID int) (int, string, error) {
+	args := m.Called(name, viewerID)
+	return args.Int(0), args.String(1), args.Error(2)
+}
+
+func (m *PostService) SetStatus(postID, userID int, status model.PostStatus, publishAt *time.Time) (*model.Post, error) {
+	args := m.Called(postID, userID, status, publishAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Post), args.Error(1)
+}
+
+func (m *PostService) Search(query, cursor string, limit int, withTotal bool) (*model.PostPage, error) {
+	args := m.Called(query, cursor, limit, withTotal)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PostPage), args.Error(1)
+}
+
 func (m *PostService) Delete(id int, userID int) error {
 	args := m.Called(id, userID)
 	return args.Error(0)
 }
 
-func (m *PostService) GetHistory(postID int) ([]*model.PostHistory, error) {
-	args := m.Called(postID)
+func (m *PostService) GetHistory(postID, viewerID int) ([]*model.PostHistory, error) {
+	args := m.Called(postID, viewerID)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*model.PostHistory), args.Error(1)
-}
\ No newline at end of file
+}
+
+func (m *PostService) GetVersion(postID, version, viewerID int) (*model.PostHistory, string, error) {
+	args := m.Called(postID, version, viewerID)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*model.PostHistory), args.String(1), args.Error(2)
+}
+
+func (m *PostService) Diff(postID, fromVersion, toVersion, viewerID int) (*model.PostDiff, error) {
+	args := m.Called(postID, fromVersion, toVersion, viewerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PostDiff), args.Error(1)
+}
+
+func (m *PostService) Restore(postID, version, userID int) (*model.Post, error) {
+	args := m.Called(postID, version, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Post), args.Error(1)
+}
+
+func (m *PostService) ContentURL(postID int, ttl time.Duration, viewerID int) (string, error) {
+	args := m.Called(postID, ttl, viewerID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *PostService) Trash(userID int) ([]*model.Post, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Post), args.Error(1)
+}
+
+func (m *PostService) Undelete(postID, userID int) (*model.Post, error) {
+	args := m.Called(postID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Post), args.Error(1)
+}
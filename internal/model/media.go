@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// Media is an uploaded file, such as a post cover or an inline image.
+// The file itself lives in FileStorage at Path.
+type Media struct {
+	ID           int            `json:"id"`
+	UserID       int            `json:"user_id"`
+	Path         string         `json:"-"`
+	ContentType  string         `json:"content_type"`
+	Size         int64          `json:"size"`
+	OriginalName string         `json:"original_name"`
+	Width        int            `json:"width,omitempty"` // zero when the original couldn't be decoded
+	Height       int            `json:"height,omitempty"`
+	Variants     []MediaVariant `json:"variants,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// MediaVariant is a resized copy of an uploaded image, e.g. a thumbnail.
+type MediaVariant struct {
+	Name   string `json:"name"`
+	Path   string `json:"-"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Size   int64  `json:"size"`
+}
@@ -1,26 +1,122 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"go-blog/internal/diff"
+)
+
+// PostStatus controls who can see a post.
+type PostStatus string
+
+const (
+	// StatusDraft posts are only visible to their owner.
+	StatusDraft PostStatus = "draft"
+	// StatusPublished posts are public.
+	StatusPublished PostStatus = "published"
+	// StatusScheduled posts become public at their PublishedAt time.
+	StatusScheduled PostStatus = "scheduled"
+	// StatusArchived posts were public once and are now only visible to
+	// their owner.
+	StatusArchived PostStatus = "archived"
+)
 
 // Post represents the metadata for a blog post stored in the database.
 type Post struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	Title     string    `json:"title"`
-	SubTitle  string    `json:"sub_title"`
-	Image     string    `json:"image"`
-	Tags      []string  `json:"tags"`
-	ContentPath string    `json:"-"` // Path to the markdown file in storage (local or S3)
-	Version   int       `json:"version"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-}
-
-// PostHistory tracks changes to a post.
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Title       string     `json:"title"`
+	Slug        string     `json:"slug"` // Names the post in URLs; empty until backfilled for older posts
+	SubTitle    string     `json:"sub_title"`
+	Image       string     `json:"image"`
+	Tags        []string   `json:"tags"`
+	ContentPath string     `json:"-"` // Path to the markdown file in storage (local or S3)
+	Version     int        `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Status      PostStatus `json:"status"`
+	// PublishedAt is when the post went live, or for scheduled posts when
+	// it will.
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	// DeletedAt is set while the post is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Rank is the relevance of a search result. Search results are paged
+	// by it, so it is part of their cursors.
+	Rank float32 `json:"-"`
+}
+
+// PostFilter narrows a post listing. Zero values don't filter.
+type PostFilter struct {
+	// Tags selects posts with any of the tags, or with all of them when
+	// MatchAllTags is set.
+	Tags         []string
+	MatchAllTags bool
+	UserID       int
+	// CreatedAfter and CreatedBefore bound the creation time, exclusively.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// PostPage is one page of a post listing. The cursors are empty when there
+// is no page in that direction. Total is only counted on request.
+type PostPage struct {
+	Items      []*Post `json:"items"`
+	NextCursor string  `json:"next_cursor"`
+	PrevCursor string  `json:"prev_cursor"`
+	Total      *int    `json:"total,omitempty"`
+}
+
+// IsPublic reports whether anyone may see the post at time now. Scheduled
+// posts count as public as soon as their time has come, even before the
+// scheduler has marked them published.
+func (p *Post) IsPublic(now time.Time) bool {
+	switch p.Status {
+	case StatusPublished:
+		return true
+	case StatusScheduled:
+		return p.PublishedAt != nil && !p.PublishedAt.After(now)
+	default:
+		return false
+	}
+}
+
+// PostHistory tracks changes to a post. Besides the markdown file it keeps a
+// snapshot of the metadata the post had at that version, unless the version
+// was archived before snapshots were introduced.
 type PostHistory struct {
-	ID        int       `json:"id"`
-	PostID    int       `json:"post_id"`
-	Version   int       `json:"version"`
-	ContentPath string    `json:"content_path"` // Path to the historical version of the markdown file
-	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID          int       `json:"id"`
+	PostID      int       `json:"post_id"`
+	Version     int       `json:"version"`
+	Title       string    `json:"title"`
+	SubTitle    string    `json:"sub_title"`
+	Image       string    `json:"image"`
+	Tags        []string  `json:"tags"`
+	AuthorID    int       `json:"author_id,omitempty"` // The user who wrote this version
+	ContentPath string    `json:"-"`                   // Path to the historical version of the markdown file
+	CreatedAt   time.Time `json:"created_at"`
+	// MetadataCaptured is false for versions archived before snapshots
+	// existed, whose metadata fields are all empty.
+	MetadataCaptured bool `json:"metadata_captured"`
+}
+
+// HasMetadata reports whether the version carries a metadata snapshot.
+func (h *PostHistory) HasMetadata() bool {
+	return h.MetadataCaptured
+}
+
+// FieldChange is a metadata field that differs between two post versions.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// PostDiff describes the line-level changes between two versions of a post.
+type PostDiff struct {
+	PostID      int           `json:"post_id"`
+	FromVersion int           `json:"from_version"`
+	ToVersion   int           `json:"to_version"`
+	Metadata    []FieldChange `json:"metadata"`
+	Unified     string        `json:"unified"`
+	Hunks       []diff.Hunk   `json:"hunks"`
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"go-blog/internal/storage"
+	"go-blog/internal/store"
+)
+
+// versionedFilePattern matches the files written by LayoutVersioned and
+// captures the owner's user ID.
+var versionedFilePattern = regexp.MustCompile(`^user_(\d+)/post_\d+_v\d+\.md$`)
+
+// ConvertReport summarises a conversion to the content-addressed layout.
+type ConvertReport struct {
+	Converted int `json:"converted"`
+	Blobs     int `json:"blobs"`
+}
+
+// ContentConverter moves the content of existing posts and archived
+// versions from LayoutVersioned files to LayoutCAS blobs.
+type ContentConverter struct {
+	postStore store.PostStore
+	uow       store.UnitOfWork
+	storage   storage.Resolver
+	dryRun    bool
+}
+
+// NewContentConverter creates a ContentConverter. With dryRun set, files are
+// read and hashed but nothing is written.
+func NewContentConverter(ps store.PostStore, uow store.UnitOfWork, resolver storage.Resolver, dryRun bool) *ContentConverter {
+	return &ContentConverter{postStore: ps, uow: uow, storage: resolver, dryRun: dryRun}
+}
+
+// Run converts every referenced versioned file. Each file is copied to its
+// blob before the references are repointed, so an interrupted run can
+// simply be started again. The versioned files are left in place: once
+// nothing references them the content GC removes them, which also covers a
+// version archived with the old path while the conversion was running.
+func (c *ContentConverter) Run() (*ConvertReport, error) {
+	paths, err := c.postStore.ListContentPaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load referenced content paths: %w", err)
+	}
+
+	report := &ConvertReport{}
+	blobs := make(map[string]bool)
+	for _, path := range paths {
+		m := versionedFilePattern.FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+		userID, err := strconv.Atoi(m[1])
+		if err != nil {
+			return report, fmt.Errorf("invalid content path %s: %w", path, err)
+		}
+
+		fs, err := c.storage.ForUser(userID)
+		if err != nil {
+			return report, err
+		}
+		content, err := fs.Read(path)
+		if err != nil {
+			return report, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		blob := blobPath(userID, content)
+
+		if !c.dryRun {
+			if !blobs[blob] {
+				if err := fs.Save(blob, content); err != nil {
+					return report, fmt.Errorf("failed to write %s: %w", blob, err)
+				}
+			}
+			err := c.uow.Do(func(posts store.PostStore) error {
+				return posts.ReplaceContentPath(path, blob)
+			})
+			if err != nil {
+				return report, fmt.Errorf("failed to repoint %s: %w", path, err)
+			}
+		}
+
+		blobs[blob] = true
+		report.Converted++
+	}
+
+	report.Blobs = len(blobs)
+	return report, nil
+}
@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentConverter_Run(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	fileStorage := storage.NewMemoryStorage()
+	uow := &MockUnitOfWork{posts: mockPostStore}
+
+	fileStorage.Save("user_1/post_1_v1.md", []byte("draft"))
+	fileStorage.Save("user_1/post_1_v2.md", []byte("draft"))
+	fileStorage.Save("user_1/post_2_v1.md", []byte("other"))
+	sum := sha256.Sum256([]byte("draft"))
+	draft := "user_1/blobs/" + hex.EncodeToString(sum[:]) + ".md"
+	sum = sha256.Sum256([]byte("other"))
+	other := "user_1/blobs/" + hex.EncodeToString(sum[:]) + ".md"
+
+	mockPostStore.On("ListContentPaths").Return([]string{
+		"user_1/post_1_v1.md", "user_1/post_1_v2.md", "user_1/post_2_v1.md", draft,
+	}, nil)
+	mockPostStore.On("ReplaceContentPath", "user_1/post_1_v1.md", draft).Return(nil).Once()
+	mockPostStore.On("ReplaceContentPath", "user_1/post_1_v2.md", draft).Return(nil).Once()
+	mockPostStore.On("ReplaceContentPath", "user_1/post_2_v1.md", other).Return(nil).Once()
+
+	// A dry run only reads.
+	report, err := service.NewContentConverter(mockPostStore, uow, storage.NewSharedResolver(fileStorage), true).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Converted)
+	assert.Equal(t, 2, report.Blobs)
+	exists, _ := fileStorage.Exists(draft)
+	assert.False(t, exists)
+	mockPostStore.AssertNotCalled(t, "ReplaceContentPath", "user_1/post_1_v1.md", draft)
+
+	report, err = service.NewContentConverter(mockPostStore, uow, storage.NewSharedResolver(fileStorage), false).Run()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Converted)
+	assert.Equal(t, 2, report.Blobs)
+
+	content, err := fileStorage.Read(draft)
+	assert.NoError(t, err)
+	assert.Equal(t, "draft", string(content))
+	content, err = fileStorage.Read(other)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", string(content))
+
+	// The old files are left for the content GC.
+	exists, _ = fileStorage.Exists("user_1/post_1_v1.md")
+	assert.True(t, exists)
+
+	mockPostStore.AssertExpectations(t)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"go-blog/internal/storage"
+	"go-blog/internal/store"
+)
+
+// contentFilePattern matches the files postService writes for post versions
+// in either content layout. Anything else in storage (e.g. files put there
+// by hand) is left alone.
+var contentFilePattern = regexp.MustCompile(`^user_\d+/(post_\d+_v\d+|blobs/[0-9a-f]{64})\.md$`)
+
+// GCReport summarises a content garbage collection run.
+type GCReport struct {
+	Scanned int                `json:"scanned"`
+	Orphans []storage.FileInfo `json:"orphans"`
+	Deleted int                `json:"deleted"`
+}
+
+// ContentGC finds post content files that neither a post nor an archived
+// version references, e.g. files left behind by a failed write, and
+// optionally deletes them.
+type ContentGC struct {
+	postStore     store.PostStore
+	storage       storage.Resolver
+	gracePeriod   time.Duration
+	deleteOrphans bool
+}
+
+// NewContentGC creates a ContentGC. Files younger than gracePeriod are never
+// reported, so content belonging to a write that is still in flight is safe.
+// When deleteOrphans is false the collector only reports what it finds.
+func NewContentGC(ps store.PostStore, resolver storage.Resolver, gracePeriod time.Duration, deleteOrphans bool) *ContentGC {
+	return &ContentGC{
+		postStore:     ps,
+		storage:       resolver,
+		gracePeriod:   gracePeriod,
+		deleteOrphans: deleteOrphans,
+	}
+}
+
+// Sweep runs a single collection pass over every storage the resolver manages.
+func (g *ContentGC) Sweep() (*GCReport, error) {
+	storages, err := g.storage.All()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storages: %w", err)
+	}
+
+	// List files before loading references: a file that is committed while
+	// we work is then guaranteed to show up as referenced.
+	type listing struct {
+		storage storage.FileStorage
+		files   []storage.FileInfo
+	}
+	listings := make([]listing, 0, len(storages))
+	for _, fs := range storages {
+		files, err := fs.List("user_")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list content files: %w", err)
+		}
+		listings = append(listings, listing{storage: fs, files: files})
+	}
+
+	paths, err := g.postStore.ListContentPaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load referenced content paths: %w", err)
+	}
+	referenced := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		referenced[p] = true
+	}
+
+	report := &GCReport{Orphans: []storage.FileInfo{}}
+	cutoff := time.Now().Add(-g.gracePeriod)
+	for _, l := range listings {
+		for _, f := range l.files {
+			if !contentFilePattern.MatchString(f.Path) {
+				continue
+			}
+			report.Scanned++
+			if referenced[f.Path] || f.ModTime.After(cutoff) {
+				continue
+			}
+
+			// A blob can gain a reference after it was listed: a new
+			// version with the same content saves it again, which
+			// moves its modification time past the cutoff.
+			if blobPattern.MatchString(f.Path) {
+				info, err := l.storage.Stat(f.Path)
+				if err != nil || info.ModTime.After(cutoff) {
+					continue
+				}
+			}
+
+			report.Orphans = append(report.Orphans, f)
+			if g.deleteOrphans {
+				if err := l.storage.Delete(f.Path); err != nil {
+					return report, fmt.Errorf("failed to delete orphaned file %s: %w", f.Path, err)
+				}
+				report.Deleted++
+			}
+		}
+	}
+
+	return report, nil
+}
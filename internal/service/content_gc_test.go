@@ -0,0 +1,93 @@
+package service_test
+
+import (
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentGC_Sweep(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockFileStorage := new(MockFileStorage)
+
+	old := time.Now().Add(-48 * time.Hour)
+	files := []storage.FileInfo{
+		{Path: "user_1/post_1_v1.md", ModTime: old},        // archived version
+		{Path: "user_1/post_1_v2.md", ModTime: old},        // current version
+		{Path: "user_1/post_2_v1.md", ModTime: old},        // orphan
+		{Path: "user_1/post_3_v1.md", ModTime: time.Now()}, // orphan, but within the grace period
+		{Path: "user_1/notes.txt", ModTime: old},           // not a post content file
+	}
+
+	mockFileStorage.On("List", "user_").Return(files, nil).Once()
+	mockPostStore.On("ListContentPaths").Return([]string{"user_1/post_1_v1.md", "user_1/post_1_v2.md"}, nil).Once()
+	mockFileStorage.On("Delete", "user_1/post_2_v1.md").Return(nil).Once()
+
+	gc := service.NewContentGC(mockPostStore, storage.NewSharedResolver(mockFileStorage), 24*time.Hour, true)
+	report, err := gc.Sweep()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.Scanned)
+	assert.Len(t, report.Orphans, 1)
+	assert.Equal(t, "user_1/post_2_v1.md", report.Orphans[0].Path)
+	assert.Equal(t, 1, report.Deleted)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestContentGC_Sweep_ReportOnly(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockFileStorage := new(MockFileStorage)
+
+	files := []storage.FileInfo{{Path: "user_1/post_2_v1.md", ModTime: time.Now().Add(-48 * time.Hour)}}
+	mockFileStorage.On("List", "user_").Return(files, nil).Once()
+	mockPostStore.On("ListContentPaths").Return([]string{}, nil).Once()
+
+	gc := service.NewContentGC(mockPostStore, storage.NewSharedResolver(mockFileStorage), 24*time.Hour, false)
+	report, err := gc.Sweep()
+
+	assert.NoError(t, err)
+	assert.Len(t, report.Orphans, 1)
+	assert.Equal(t, 0, report.Deleted)
+
+	// Nothing may be deleted in report-only mode.
+	mockFileStorage.AssertNotCalled(t, "Delete", "user_1/post_2_v1.md")
+}
+
+func TestContentGC_Sweep_Blobs(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockFileStorage := new(MockFileStorage)
+
+	old := time.Now().Add(-48 * time.Hour)
+	shared := "user_1/blobs/" + strings.Repeat("a", 64) + ".md"
+	orphan := "user_1/blobs/" + strings.Repeat("b", 64) + ".md"
+	reused := "user_1/blobs/" + strings.Repeat("c", 64) + ".md"
+	files := []storage.FileInfo{
+		{Path: shared, ModTime: old},
+		{Path: orphan, ModTime: old},
+		{Path: reused, ModTime: old},
+	}
+
+	mockFileStorage.On("List", "user_").Return(files, nil).Once()
+	mockPostStore.On("ListContentPaths").Return([]string{shared}, nil).Once()
+	mockFileStorage.On("Stat", orphan).Return(&storage.FileInfo{Path: orphan, ModTime: old}, nil).Once()
+	// Saved again by a new version after it was listed.
+	mockFileStorage.On("Stat", reused).Return(&storage.FileInfo{Path: reused, ModTime: time.Now()}, nil).Once()
+	mockFileStorage.On("Delete", orphan).Return(nil).Once()
+
+	gc := service.NewContentGC(mockPostStore, storage.NewSharedResolver(mockFileStorage), 24*time.Hour, true)
+	report, err := gc.Sweep()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Scanned)
+	assert.Len(t, report.Orphans, 1)
+	assert.Equal(t, orphan, report.Orphans[0].Path)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+)
+
+// ContentLayout decides where the markdown of a post version is stored.
+type ContentLayout string
+
+const (
+	// LayoutVersioned writes every version to its own file,
+	// user_<id>/post_<post id>_v<version>.md.
+	LayoutVersioned ContentLayout = "versioned"
+	// LayoutCAS stores content by its SHA-256 in user_<id>/blobs/<hash>.md,
+	// so versions and posts with identical content share one file.
+	LayoutCAS ContentLayout = "cas"
+)
+
+// ParseContentLayout validates a CONTENT_LAYOUT setting.
+func ParseContentLayout(s string) (ContentLayout, error) {
+	switch l := ContentLayout(s); l {
+	case LayoutVersioned, LayoutCAS:
+		return l, nil
+	default:
+		return "", fmt.Errorf("unknown content layout %q", s)
+	}
+}
+
+// blobPattern matches the files written by LayoutCAS. A blob can be
+// referenced by several posts and versions at once.
+var blobPattern = regexp.MustCompile(`^user_\d+/blobs/[0-9a-f]{64}\.md$`)
+
+func versionedPath(userID, postID, version int) string {
+	return fmt.Sprintf("user_%d/post_%d_v%d.md", userID, postID, version)
+}
+
+func blobPath(userID int, content []byte) string {
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("user_%d/blobs/%s.md", userID, hex.EncodeToString(sum[:]))
+}
+
+// contentPath returns the path a version's content is stored at.
+func (l ContentLayout) contentPath(userID, postID, version int, content []byte) string {
+	if l == LayoutCAS {
+		return blobPath(userID, content)
+	}
+	return versionedPath(userID, postID, version)
+}
@@ -4,3 +4,8 @@ import "errors"
 
 var ErrNotFound = errors.New("not found")
 var ErrPermissionDenied = errors.New("permission denied")
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+var ErrTooLarge = errors.New("file too large")
+var ErrUnknownVariant = errors.New("unknown image size")
+var ErrInvalidStatus = errors.New("invalid post status")
+var ErrInvalidCursor = errors.New("invalid page cursor")
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunEvery calls job every interval until ctx is cancelled. Errors are
+// logged and do not stop the loop.
+func RunEvery(ctx context.Context, name string, interval time.Duration, job func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(); err != nil {
+				log.Printf("%s: %v", name, err)
+			}
+		}
+	}
+}
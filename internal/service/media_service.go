@@ -0,0 +1,330 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-blog/internal/imaging"
+	"go-blog/internal/model"
+	"go-blog/internal/storage"
+	"go-blog/internal/store"
+)
+
+// resizableTypes are the uploads that get resized variants. GIFs are left
+// alone so animations survive, and WebP has no decoder in the standard library.
+var resizableTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+// mediaExtensions lists the content types accepted for upload and the
+// extension their files are stored with. SVG is deliberately missing: it
+// can carry scripts and would be served from our own origin.
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// maxOriginalName is the length, in characters, of media.original_name.
+const maxOriginalName = 255
+
+type MediaService interface {
+	// Upload stores an image for userID. The content type is sniffed from
+	// the data; the client's claimed type and file extension are ignored.
+	Upload(userID int, filename string, content io.Reader) (*model.Media, error)
+	GetByID(id int) (*model.Media, error)
+	// GetByPath returns the media describing the file stored at path, which
+	// may be the original or one of its variants.
+	GetByPath(path string) (*model.Media, error)
+	// Open returns a media item together with a reader for its file.
+	// The caller must close the reader.
+	Open(id int) (*model.Media, io.ReadCloser, error)
+	// OpenVariant is like Open but reads the named resized variant. The
+	// returned media describes the file being read. Images too small to
+	// have that variant are served as the original.
+	OpenVariant(id int, size string) (*model.Media, io.ReadCloser, error)
+	URL(id int, size string, ttl time.Duration) (string, error)
+	ListByUser(userID int) ([]*model.Media, error)
+}
+
+type mediaService struct {
+	mediaStore store.MediaStore
+	storage    storage.Resolver
+	maxSize    int64
+}
+
+// NewMediaService creates a MediaService that rejects files larger than maxSize bytes.
+func NewMediaService(ms store.MediaStore, resolver storage.Resolver, maxSize int64) MediaService {
+	return &mediaService{mediaStore: ms, storage: resolver, maxSize: maxSize}
+}
+
+// originalName makes a client-supplied filename fit media.original_name.
+// It is only shown to the uploader, so a shortened name will do.
+func originalName(filename string) string {
+	filename = strings.ToValidUTF8(filename, "\uFFFD")
+	if runes := []rune(filename); len(runes) > maxOriginalName {
+		filename = string(runes[:maxOriginalName])
+	}
+	return filename
+}
+
+func (s *mediaService) Upload(userID int, filename string, content io.Reader) (*model.Media, error) {
+	fs, err := s.storage.ForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// http.DetectContentType looks at no more than the first 512 bytes.
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	ext, ok := mediaExtensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("user_%d/media/%s%s", userID, name, ext)
+
+	body := &limitedReader{r: io.MultiReader(bytes.NewReader(head), content), remaining: s.maxSize}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		if body.exceeded {
+			return nil, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, s.maxSize)
+		}
+		return nil, err
+	}
+	// Originals are served as they are stored, so EXIF data such as the
+	// GPS position a photo was taken at must not make it into storage.
+	data, err = imaging.StripMetadata(data, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrUnsupportedMediaType, contentType, err)
+	}
+	if err := fs.Save(path, data); err != nil {
+		return nil, fmt.Errorf("failed to save media: %w", err)
+	}
+
+	media := &model.Media{
+		UserID:       userID,
+		Path:         path,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		OriginalName: originalName(filename),
+	}
+	if resizableTypes[contentType] {
+		// The original is usable without variants, so this is not fatal.
+		if err := s.createVariants(fs, media, data); err != nil {
+			log.Printf("could not create variants of %s: %v", path, err)
+		}
+	}
+
+	created, err := s.mediaStore.Create(media)
+	if err != nil {
+		s.discard(fs, path)
+		for _, v := range media.Variants {
+			s.discard(fs, v.Path)
+		}
+		return nil, err
+	}
+	return created, nil
+}
+
+// createVariants decodes the original, records its dimensions and saves a
+// JPEG of every variant narrower than it. Nothing is left behind in storage
+// when it fails.
+func (s *mediaService) createVariants(fs storage.FileStorage, media *model.Media, data []byte) error {
+	img, err := imaging.Decode(data)
+	if err != nil {
+		return err
+	}
+	media.Width, media.Height = img.Bounds().Dx(), img.Bounds().Dy()
+
+	base := strings.TrimSuffix(media.Path, filepath.Ext(media.Path))
+	var variants []model.MediaVariant
+	for _, v := range imaging.Variants {
+		if media.Width <= v.Width {
+			break
+		}
+
+		resized := imaging.Fit(img, v.Width)
+		var buf bytes.Buffer
+		if err := imaging.EncodeJPEG(&buf, resized); err != nil {
+			return err
+		}
+
+		path := fmt.Sprintf("%s_%s.jpg", base, v.Name)
+		if err := fs.Save(path, buf.Bytes()); err != nil {
+			for _, saved := range variants {
+				s.discard(fs, saved.Path)
+			}
+			return err
+		}
+		variants = append(variants, model.MediaVariant{
+			Name:   v.Name,
+			Path:   path,
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Size:   int64(buf.Len()),
+		})
+	}
+
+	media.Variants = variants
+	return nil
+}
+
+// discard removes a file written by an upload that did not complete.
+func (s *mediaService) discard(fs storage.FileStorage, path string) {
+	if err := fs.Delete(path); err != nil {
+		log.Printf("could not remove orphaned media file %s: %v", path, err)
+	}
+}
+
+func (s *mediaService) GetByID(id int) (*model.Media, error) {
+	media, err := s.mediaStore.GetByID(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return media, nil
+}
+
+func (s *mediaService) GetByPath(path string) (*model.Media, error) {
+	media, err := s.mediaStore.GetByPath(path)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	for _, v := range media.Variants {
+		if v.Path == path {
+			return describeVariant(media, v), nil
+		}
+	}
+	served := *media
+	served.Variants = nil
+	return &served, nil
+}
+
+func (s *mediaService) Open(id int) (*model.Media, io.ReadCloser, error) {
+	return s.open(id, "")
+}
+
+func (s *mediaService) OpenVariant(id int, size string) (*model.Media, io.ReadCloser, error) {
+	return s.open(id, size)
+}
+
+// URL returns a signed storage URL for a media item, or for one of its
+// variants when size is set. It returns storage.ErrURLNotSupported when the
+// storage can't sign URLs.
+func (s *mediaService) URL(id int, size string, ttl time.Duration) (string, error) {
+	media, fs, err := s.locate(id, size)
+	if err != nil {
+		return "", err
+	}
+	return storage.URL(fs, media.Path, ttl)
+}
+
+func (s *mediaService) open(id int, size string) (*model.Media, io.ReadCloser, error) {
+	media, fs, err := s.locate(id, size)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := fs.ReadStream(media.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read media %d: %w", id, err)
+	}
+	return media, r, nil
+}
+
+// locate finds the file to serve for a media item and size, where an empty
+// size means the original. The returned media describes that file.
+func (s *mediaService) locate(id int, size string) (*model.Media, storage.FileStorage, error) {
+	if size != "" {
+		if _, ok := imaging.LookupVariant(size); !ok {
+			return nil, nil, fmt.Errorf("%w: %q", ErrUnknownVariant, size)
+		}
+	}
+
+	media, err := s.GetByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	served := *media
+	served.Variants = nil
+	for _, v := range media.Variants {
+		if v.Name == size {
+			served = *describeVariant(media, v)
+			break
+		}
+	}
+
+	fs, err := s.storage.ForUser(media.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &served, fs, nil
+}
+
+// describeVariant returns a copy of media that describes variant v.
+func describeVariant(media *model.Media, v model.MediaVariant) *model.Media {
+	served := *media
+	served.Variants = nil
+	served.Path = v.Path
+	served.ContentType = "image/jpeg"
+	served.Size = v.Size
+	served.Width, served.Height = v.Width, v.Height
+	return &served
+}
+
+func (s *mediaService) ListByUser(userID int) ([]*model.Media, error) {
+	return s.mediaStore.ListByUser(userID)
+}
+
+// randomName returns an unguessable file name, so media URLs in storage
+// can't be enumerated.
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// limitedReader fails once more than remaining bytes have been read,
+// unlike io.LimitReader which silently truncates.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// Ask for one byte past the limit so we can tell "exactly at the limit"
+	// apart from "over it".
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		return 0, ErrTooLarge
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,277 @@
+package service_test
+
+import (
+	"bytes"
+	"errors"
+	"go-blog/internal/model"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMediaStore is a mock implementation of store.MediaStore
+type MockMediaStore struct {
+	mock.Mock
+}
+
+func (m *MockMediaStore) Create(media *model.Media) (*model.Media, error) {
+	args := m.Called(media)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	// Tests can return a func to fill in database-generated fields.
+	if fn, ok := args.Get(0).(func(*model.Media) *model.Media); ok {
+		return fn(media), args.Error(1)
+	}
+	return args.Get(0).(*model.Media), args.Error(1)
+}
+
+func (m *MockMediaStore) GetByID(id int) (*model.Media, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Media), args.Error(1)
+}
+
+func (m *MockMediaStore) GetByPath(path string) (*model.Media, error) {
+	args := m.Called(path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Media), args.Error(1)
+}
+
+func (m *MockMediaStore) ListByUser(userID int) ([]*model.Media, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Media), args.Error(1)
+}
+
+func (m *MockMediaStore) ListPaths() ([]string, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// pngData is a tiny PNG without metadata, so it is stored unchanged.
+var pngData = func() []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}()
+
+func TestMediaService_Upload(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	fileStorage := storage.NewMemoryStorage()
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(fileStorage), 1<<20)
+
+	mockMediaStore.On("Create", mock.MatchedBy(func(m *model.Media) bool {
+		return m.UserID == 1 && m.ContentType == "image/png" && m.Size == int64(len(pngData)) &&
+			m.OriginalName == "cover.png" && strings.HasPrefix(m.Path, "user_1/media/") && strings.HasSuffix(m.Path, ".png")
+	})).Return(func(m *model.Media) *model.Media {
+		m.ID = 7
+		return m
+	}, nil).Once()
+
+	media, err := mediaSvc.Upload(1, "cover.png", bytes.NewReader(pngData))
+	require.NoError(t, err)
+	assert.Equal(t, 7, media.ID)
+
+	stored, err := fileStorage.Read(media.Path)
+	require.NoError(t, err)
+	assert.Equal(t, pngData, stored)
+	mockMediaStore.AssertExpectations(t)
+}
+
+func TestMediaService_Upload_LongFilename(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(storage.NewMemoryStorage()), 1<<20)
+
+	// The name is cut to what the column holds, counted in characters.
+	name := strings.Repeat("ả", 300) + ".png"
+	mockMediaStore.On("Create", mock.MatchedBy(func(m *model.Media) bool {
+		return m.OriginalName == strings.Repeat("ả", 255)
+	})).Return(func(m *model.Media) *model.Media { return m }, nil).Once()
+
+	_, err := mediaSvc.Upload(1, name, bytes.NewReader(pngData))
+	require.NoError(t, err)
+	mockMediaStore.AssertExpectations(t)
+}
+
+func TestMediaService_Upload_StripsMetadata(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	fileStorage := storage.NewMemoryStorage()
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(fileStorage), 1<<20)
+
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2)), nil))
+	clean := buf.Bytes()
+	exif := "Exif\x00\x00GPS 52.37N 4.89E"
+	data := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	data = append(data, clean[2:]...)
+
+	mockMediaStore.On("Create", mock.MatchedBy(func(m *model.Media) bool {
+		return m.Size == int64(len(clean))
+	})).Return(func(m *model.Media) *model.Media { return m }, nil).Once()
+
+	media, err := mediaSvc.Upload(1, "holiday.jpg", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	stored, err := fileStorage.Read(media.Path)
+	require.NoError(t, err)
+	assert.Equal(t, clean, stored)
+	mockMediaStore.AssertExpectations(t)
+}
+
+func TestMediaService_Upload_RejectsNonImages(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	fileStorage := storage.NewMemoryStorage()
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(fileStorage), 1<<20)
+
+	// The file name claims an image, but the content decides.
+	_, err := mediaSvc.Upload(1, "evil.png", strings.NewReader("<html><script>alert(1)</script></html>"))
+	assert.ErrorIs(t, err, service.ErrUnsupportedMediaType)
+
+	files, err := fileStorage.List("user_1/")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	mockMediaStore.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestMediaService_Upload_TooLarge(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	fileStorage := storage.NewMemoryStorage()
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(fileStorage), int64(len(pngData)-1))
+
+	_, err := mediaSvc.Upload(1, "cover.png", bytes.NewReader(pngData))
+	assert.ErrorIs(t, err, service.ErrTooLarge)
+
+	files, err := fileStorage.List("user_1/")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	mockMediaStore.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestMediaService_Upload_RemovesFileWhenStoreFails(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	fileStorage := storage.NewMemoryStorage()
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(fileStorage), 1<<20)
+
+	mockMediaStore.On("Create", mock.Anything).Return(nil, errors.New("db down")).Once()
+
+	_, err := mediaSvc.Upload(1, "cover.png", bytes.NewReader(pngData))
+	assert.Error(t, err)
+
+	files, err := fileStorage.List("user_1/")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestMediaService_Open(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	fileStorage := storage.NewMemoryStorage()
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(fileStorage), 1<<20)
+
+	require.NoError(t, fileStorage.Save("user_1/media/abc.png", pngData))
+	mockMediaStore.On("GetByID", 7).Return(&model.Media{ID: 7, UserID: 1, Path: "user_1/media/abc.png", ContentType: "image/png"}, nil).Once()
+	mockMediaStore.On("GetByID", 8).Return(nil, errors.New("sql: no rows in result set")).Once()
+
+	media, r, err := mediaSvc.Open(7)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", media.ContentType)
+	assert.Equal(t, pngData, data)
+
+	_, _, err = mediaSvc.Open(8)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestMediaService_GetByPath(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(storage.NewMemoryStorage()), 1<<20)
+
+	stored := &model.Media{ID: 7, UserID: 1, Path: "user_1/media/abc.png", ContentType: "image/png", Variants: []model.MediaVariant{
+		{Name: "thumb", Path: "user_1/media/abc_thumb.jpg", Width: 150, Height: 100, Size: 42},
+	}}
+	mockMediaStore.On("GetByPath", "user_1/media/abc.png").Return(stored, nil).Once()
+	mockMediaStore.On("GetByPath", "user_1/media/abc_thumb.jpg").Return(stored, nil).Once()
+	mockMediaStore.On("GetByPath", "user_1/notes.html").Return(nil, errors.New("sql: no rows in result set")).Once()
+
+	media, err := mediaSvc.GetByPath("user_1/media/abc.png")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", media.ContentType)
+
+	media, err = mediaSvc.GetByPath("user_1/media/abc_thumb.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", media.ContentType)
+	assert.Equal(t, int64(42), media.Size)
+
+	_, err = mediaSvc.GetByPath("user_1/notes.html")
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestMediaService_Upload_CreatesVariants(t *testing.T) {
+	mockMediaStore := new(MockMediaStore)
+	fileStorage := storage.NewMemoryStorage()
+	mediaSvc := service.NewMediaService(mockMediaStore, storage.NewSharedResolver(fileStorage), 10<<20)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1000, 500))))
+
+	mockMediaStore.On("Create", mock.Anything).Return(func(m *model.Media) *model.Media {
+		m.ID = 7
+		return m
+	}, nil).Once()
+
+	media, err := mediaSvc.Upload(1, "wide.png", &buf)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, media.Width)
+	assert.Equal(t, 500, media.Height)
+
+	// 1000px is too narrow for the 1600px variant; images are never upscaled.
+	require.Len(t, media.Variants, 2)
+	assert.Equal(t, "thumb", media.Variants[0].Name)
+	assert.Equal(t, 320, media.Variants[0].Width)
+	assert.Equal(t, 160, media.Variants[0].Height)
+	assert.Equal(t, "medium", media.Variants[1].Name)
+	assert.True(t, strings.HasSuffix(media.Variants[1].Path, "_medium.jpg"))
+
+	stored, err := fileStorage.Read(media.Variants[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", http.DetectContentType(stored))
+
+	// Serving a variant the image is too small for falls back to the original.
+	mockMediaStore.On("GetByID", 7).Return(media, nil)
+	served, r, err := mediaSvc.OpenVariant(7, "large")
+	require.NoError(t, err)
+	r.Close()
+	assert.Equal(t, media.Path, served.Path)
+
+	served, r, err = mediaSvc.OpenVariant(7, "thumb")
+	require.NoError(t, err)
+	r.Close()
+	assert.Equal(t, "image/jpeg", served.ContentType)
+	assert.Equal(t, media.Variants[0].Path, served.Path)
+
+	_, _, err = mediaSvc.OpenVariant(7, "huge")
+	assert.ErrorIs(t, err, service.ErrUnknownVariant)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go-blog/internal/model"
+	"go-blog/internal/store"
+)
+
+// DefaultPageSize and MaxPageSize bound the number of posts on a page.
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+// pageLimit returns limit within the bounds of a page.
+func pageLimit(limit int) int {
+	switch {
+	case limit < 1:
+		return DefaultPageSize
+	case limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return limit
+	}
+}
+
+// cursor is what an opaque page cursor encodes: the sort key of the post
+// at the edge of a page, and which way to go from it.
+type cursor struct {
+	Before      bool      `json:"b,omitempty"`
+	Rank        float32   `json:"r,omitempty"`
+	PublishedAt time.Time `json:"t"`
+	ID          int       `json:"i"`
+}
+
+func encodeCursor(post *model.Post, before bool) string {
+	c := cursor{Before: before, Rank: post.Rank, ID: post.ID}
+	// Listed posts are public, so they always have a publish time.
+	if post.PublishedAt != nil {
+		c.PublishedAt = *post.PublishedAt
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parsePage turns a cursor from an earlier page into the page to fetch.
+// One more post than limit is asked for, to tell whether there are more.
+func parsePage(s string, limit int) (store.Page, error) {
+	page := store.Page{Limit: limit + 1}
+	if s == "" {
+		return page, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return page, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return page, ErrInvalidCursor
+	}
+
+	position := &store.Cursor{Rank: c.Rank, PublishedAt: c.PublishedAt, ID: c.ID}
+	if c.Before {
+		page.Before = position
+	} else {
+		page.After = position
+	}
+	return page, nil
+}
+
+// newPostPage builds a page from the posts fetched for page, which may hold
+// one more post than limit.
+func newPostPage(posts []*model.Post, page store.Page, limit int) *model.PostPage {
+	more := len(posts) > limit
+	if more {
+		if page.Before != nil {
+			// The extra post is the one furthest from the cursor.
+			posts = posts[1:]
+		} else {
+			posts = posts[:limit]
+		}
+	}
+
+	result := &model.PostPage{Items: posts}
+	if result.Items == nil {
+		result.Items = []*model.Post{}
+	}
+	if len(posts) == 0 {
+		return result
+	}
+
+	// The extra post tells whether there is more in the direction read.
+	// The other way there is at least the page the cursor came from.
+	hasNext := more || page.Before != nil
+	hasPrev := page.After != nil || (page.Before != nil && more)
+	if hasNext {
+		result.NextCursor = encodeCursor(posts[len(posts)-1], false)
+	}
+	if hasPrev {
+		result.PrevCursor = encodeCursor(posts[0], true)
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"go-blog/internal/model"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"go-blog/internal/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostService_List_Cursors(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	posts := make([]*model.Post, 5)
+	for i := range posts {
+		published := now.Add(-time.Duration(i) * time.Hour)
+		posts[i] = &model.Post{ID: 5 - i, PublishedAt: &published}
+	}
+
+	// The first page asks for one post more than it shows.
+	mockPostStore.On("List", model.PostFilter{}, store.Page{Limit: 3}).Return(posts[:3], nil).Once()
+	mockPostStore.On("Count", model.PostFilter{}).Return(5, nil).Once()
+
+	first, err := postSvc.List(model.PostFilter{}, "", 2, true)
+	require.NoError(t, err)
+	assert.Equal(t, posts[:2], first.Items)
+	assert.Empty(t, first.PrevCursor)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.Equal(t, 5, *first.Total)
+
+	// The next page starts after the last post shown.
+	mockPostStore.On("List", model.PostFilter{}, store.Page{Limit: 3, After: &store.Cursor{PublishedAt: *posts[1].PublishedAt, ID: posts[1].ID}}).Return(posts[2:5], nil).Once()
+
+	second, err := postSvc.List(model.PostFilter{}, first.NextCursor, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, posts[2:4], second.Items)
+	assert.NotEmpty(t, second.NextCursor)
+	assert.NotEmpty(t, second.PrevCursor)
+	assert.Nil(t, second.Total)
+
+	// Going back ends before the first post shown, and the store returns
+	// the extra post at the far end.
+	mockPostStore.On("List", model.PostFilter{}, store.Page{Limit: 3, Before: &store.Cursor{PublishedAt: *posts[2].PublishedAt, ID: posts[2].ID}}).Return(posts[0:2], nil).Once()
+
+	back, err := postSvc.List(model.PostFilter{}, second.PrevCursor, 2, false)
+	require.NoError(t, err)
+	assert.Equal(t, posts[:2], back.Items)
+	assert.Empty(t, back.PrevCursor)
+	assert.NotEmpty(t, back.NextCursor)
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_List_LastPage(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	mockPostStore.On("List", model.PostFilter{}, store.Page{Limit: 11}).Return([]*model.Post{{ID: 1}}, nil).Once()
+
+	page, err := postSvc.List(model.PostFilter{}, "", 0, false)
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Empty(t, page.NextCursor)
+	assert.Empty(t, page.PrevCursor)
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_List_CapsLimit(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	mockPostStore.On("List", model.PostFilter{}, store.Page{Limit: service.MaxPageSize + 1}).Return(nil, nil).Once()
+
+	page, err := postSvc.List(model.PostFilter{}, "", 1000000, false)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.Post{}, page.Items)
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_List_InvalidCursor(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		_, err := postSvc.List(model.PostFilter{}, cursor, 10, false)
+		assert.ErrorIs(t, err, service.ErrInvalidCursor, cursor)
+	}
+	mockPostStore.AssertNotCalled(t, "List", mock.Anything, mock.Anything)
+}
+
+func TestPostService_Search_RankInCursor(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	published := time.Now().UTC().Truncate(time.Microsecond)
+	results := []*model.Post{
+		{ID: 4, Rank: 0.0991, PublishedAt: &published},
+		{ID: 9, Rank: 0.0607927, PublishedAt: &published},
+	}
+	mockPostStore.On("Search", "golang", store.Page{Limit: 2}).Return(results, nil).Once()
+	mockPostStore.On("CountSearch", "golang").Return(7, nil).Once()
+	mockPostStore.On("Search", "golang", store.Page{Limit: 2, After: &store.Cursor{Rank: 0.0991, PublishedAt: published, ID: 4}}).Return(results[1:], nil).Once()
+
+	page, err := postSvc.Search("golang", "", 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, results[:1], page.Items)
+	assert.Equal(t, 7, *page.Total)
+
+	_, err = postSvc.Search("golang", page.NextCursor, 1, false)
+	require.NoError(t, err)
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_List_Filter(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	after := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := model.PostFilter{Tags: []string{"go", "web"}, MatchAllTags: true, UserID: 3, CreatedAfter: &after}
+	mockPostStore.On("List", filter, store.Page{Limit: 11}).Return([]*model.Post{{ID: 1}}, nil).Once()
+	mockPostStore.On("Count", filter).Return(1, nil).Once()
+
+	// The filter applies to the count as well as the page.
+	page, err := postSvc.List(filter, "", 10, true)
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	assert.Equal(t, 1, *page.Total)
+
+	mockPostStore.AssertExpectations(t)
+}
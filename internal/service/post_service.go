@@ -2,98 +2,169 @@ package service
 
 import (
 	"fmt"
-	
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"go-blog/internal/diff"
+	"go-blog/internal/slug"
 	"go-blog/internal/store"
 	"go-blog/internal/model"
 	"go-blog/internal/storage"
 )
 
 type PostService interface {
-	Create(title, subTitle, image string, tags []string, content string, userID int) (*model.Post, error)
-	GetByID(id int) (*model.Post, string, error)
-	List(page, limit int) ([]*model.Post, error)
-	CreateFromFile(title, subTitle, image string, tags []string, content []byte, userID int) (*model.Post, error)
+	// Create and CreateFromFile publish the post right away when status is
+	// empty. publishAt is only given for StatusScheduled.
+	Create(title, subTitle, image string, tags []string, content string, status model.PostStatus, publishAt *time.Time, userID int) (*model.Post, error)
+	// GetByID, GetHistory, GetVersion, Diff and ContentURL only find posts
+	// that are public or owned by viewerID. Anonymous viewers pass 0.
+	GetByID(id, viewerID int) (*model.Post, string, error)
+	// List returns a page of the public posts filter selects, newest first.
+	// cursor is empty for the first page, or a cursor of the page next to
+	// the one wanted. The total is only counted when withTotal is set.
+	List(filter model.PostFilter, cursor string, limit int, withTotal bool) (*model.PostPage, error)
+	// ListOwn returns a user's posts whatever their status, newest first.
+	ListOwn(userID, page, limit int) ([]*model.Post, error)
+	CreateFromFile(title, subTitle, image string, tags []string, content io.Reader, status model.PostStatus, publishAt *time.Time, userID int) (*model.Post, error)
 	Update(postID int, title, subTitle, image string, tags []string, content string, userID int) (*model.Post, error)
-	Search(query string, page, limit int) ([]*model.Post, error)
+	// Search pages through public posts matching query like List does,
+	// best match first.
+	Search(query, cursor string, limit int, withTotal bool) (*model.PostPage, error)
+	GetHistory(postID, viewerID int) ([]*model.PostHistory, error)
+	GetVersion(postID, version, viewerID int) (*model.PostHistory, string, error)
+	Diff(postID, fromVersion, toVersion, viewerID int) (*model.PostDiff, error)
+	Restore(postID, version, userID int) (*model.Post, error)
+	// ContentURL returns a signed storage URL for the markdown of the
+	// current version, or storage.ErrURLNotSupported.
+	ContentURL(postID int, ttl time.Duration, viewerID int) (string, error)
+	// ResolveSlug finds the post a slug names for viewerID and returns its
+	// ID and current slug. The two slugs differ when the post was renamed
+	// since, in which case callers redirect to the current one.
+	ResolveSlug(name string, viewerID int) (int, string, error)
+	// SetStatus publishes, schedules, archives or unpublishes a post.
+	// publishAt is required for StatusScheduled and must be in the future.
+	SetStatus(postID, userID int, status model.PostStatus, publishAt *time.Time) (*model.Post, error)
+	// Delete moves a post to its owner's trash, which hides it everywhere
+	// else until it is restored or purged.
+	Delete(postID, userID int) error
+	// Trash lists a user's deleted posts, most recently deleted first.
+	Trash(userID int) ([]*model.Post, error)
+	// Undelete takes a post out of the trash.
+	Undelete(postID, userID int) (*model.Post, error)
 }
 
 type postService struct {
-	postStore   store.PostStore
-	fileStorage storage.FileStorage
+	postStore store.PostStore
+	uow       store.UnitOfWork
+	storage   storage.Resolver
+	layout    ContentLayout
 }
 
-func NewPostService(ps store.PostStore, fs storage.FileStorage) PostService {
-	return &postService{postStore: ps, fileStorage: fs}
+func NewPostService(ps store.PostStore, uow store.UnitOfWork, resolver storage.Resolver, layout ContentLayout) PostService {
+	return &postService{postStore: ps, uow: uow, storage: resolver, layout: layout}
 }
 
-func (s *postService) Create(title, subTitle, image string, tags []string, content string, userID int) (*model.Post, error) {
-	// Create the post metadata in the database first to get an ID.
-	post := &model.Post{
-		UserID:   userID,
-		Title:    title,
-		SubTitle: subTitle,
-		Image:    image,
-		Tags:     tags,
-		Version:  1,
+func (s *postService) Create(title, subTitle, image string, tags []string, content string, status model.PostStatus, publishAt *time.Time, userID int) (*model.Post, error) {
+	data := []byte(content)
+	return s.create(title, subTitle, image, tags, status, publishAt, userID, data, func(fs storage.FileStorage, path string) error {
+		return fs.Save(path, data)
+	})
+}
+
+// CreateFromFile creates a post whose markdown is streamed from content,
+// e.g. an uploaded file, without reading it into memory first.
+func (s *postService) CreateFromFile(title, subTitle, image string, tags []string, content io.Reader, status model.PostStatus, publishAt *time.Time, userID int) (*model.Post, error) {
+	// A blob is named after the hash of its content, so in the
+	// content-addressed layout the file has to be read before it is stored.
+	if s.layout == LayoutCAS {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read post content: %w", err)
+		}
+		return s.Create(title, subTitle, image, tags, string(data), status, publishAt, userID)
 	}
 
-	createdPost, err := s.postStore.Create(post)
+	return s.create(title, subTitle, image, tags, status, publishAt, userID, nil, func(fs storage.FileStorage, path string) error {
+		return fs.SaveStream(path, content)
+	})
+}
+
+// create inserts a new post and stores its first version using save.
+// content is only needed to name blobs and may be nil for LayoutVersioned.
+func (s *postService) create(title, subTitle, image string, tags []string, status model.PostStatus, publishAt *time.Time, userID int, content []byte, save func(fs storage.FileStorage, path string) error) (*model.Post, error) {
+	if status == "" {
+		status = model.StatusPublished
+	}
+	publishedAt, err := publishTime(status, publishAt, nil, time.Now())
 	if err != nil {
 		return nil, err
 	}
 
-	// Use the post ID to create a unique path for the content file.
-	// Path format: user_<userID>/post_<postID>_v1.md
-	contentPath := fmt.Sprintf("user_%d/post_%d_v%d.md", userID, createdPost.ID, createdPost.Version)
-
-	// Save the markdown content to the configured storage (local or S3).
-	if err := s.fileStorage.Save(contentPath, []byte(content)); err != nil {
-		// TODO: Consider rolling back the database transaction if file storage fails.
+	fs, err := s.storage.ForUser(userID)
+	if err != nil {
 		return nil, err
 	}
 
-	// Update the post record with the content path.
-	createdPost.ContentPath = contentPath
-	return s.postStore.Update(createdPost)
-}
-
-func (s *postService) CreateFromFile(title, subTitle, image string, tags []string, content []byte, userID int) (*model.Post, error) {
-	// Create the post metadata in the database first to get an ID.
 	post := &model.Post{
-		UserID:   userID,
-		Title:    title,
-		SubTitle: subTitle,
-		Image:    image,
-		Tags:     tags,
-		Version:  1,
+		UserID:      userID,
+		Title:       title,
+		SubTitle:    subTitle,
+		Image:       image,
+		Tags:        tags,
+		Version:     1,
+		Status:      status,
+		PublishedAt: publishedAt,
 	}
 
-	createdPost, err := s.postStore.Create(post)
-	if err != nil {
-		return nil, err
-	}
+	var createdPost *model.Post
+	var contentPath string
+	err = s.uow.Do(func(posts store.PostStore) error {
+		// Create the post metadata in the database first to get an ID.
+		created, err := posts.Create(post)
+		if err != nil {
+			return err
+		}
 
-	// Use the post ID to create a unique path for the content file.
-	contentPath := fmt.Sprintf("user_%d/post_%d_v%d.md", userID, createdPost.ID, createdPost.Version)
+		// The ID breaks ties between posts with the same title.
+		created.Slug, err = assignSlug(posts, title, created.ID, "")
+		if err != nil {
+			return err
+		}
 
-	// Save the markdown content to the configured storage.
-	if err := s.fileStorage.Save(contentPath, content); err != nil {
-		// TODO: Consider rolling back the database transaction if file storage fails.
+		// Use the post ID to create a unique path for the content file.
+		// Path format: user_<userID>/post_<postID>_v1.md, or
+		// user_<userID>/blobs/<sha256>.md in the content-addressed layout.
+		contentPath = s.layout.contentPath(userID, created.ID, created.Version, content)
+
+		// Save the markdown content to the configured storage (local or S3).
+		// An existing blob is written again all the same, which refreshes
+		// its modification time so the GC's grace period covers it.
+		if err := save(fs, contentPath); err != nil {
+			return err
+		}
+
+		// Update the post record with the content path.
+		created.ContentPath = contentPath
+		createdPost, err = posts.Update(created)
+		return err
+	})
+	if err != nil {
+		s.discard(fs, contentPath)
 		return nil, err
 	}
 
-	// Update the post record with the content path.
-	createdPost.ContentPath = contentPath
-	return s.postStore.Update(createdPost)
+	return createdPost, nil
 }
 
-func (s *postService) GetByID(id int) (*model.Post, string, error) {
-	post, err := s.postStore.GetByID(id)
+func (s *postService) GetByID(id, viewerID int) (*model.Post, string, error) {
+	post, err := s.visiblePost(id, viewerID)
 	if err != nil {
-		return nil, "", ErrNotFound
+		return nil, "", err
 	}
 
-	content, err := s.fileStorage.Read(post.ContentPath)
+	content, err := s.readContent(post.UserID, post.ContentPath)
 	if err != nil {
 		// If we can't read the file, the post is in an inconsistent state.
 		return nil, "", fmt.Errorf("could not read content for post %d: %w", id, err)
@@ -102,19 +173,132 @@ func (s *postService) GetByID(id int) (*model.Post, string, error) {
 	return post, string(content), nil
 }
 
-func (s *postService) List(page, limit int) ([]*model.Post, error) {
-	offset := (page - 1) * limit
-	return s.postStore.List(limit, offset)
+func (s *postService) ContentURL(postID int, ttl time.Duration, viewerID int) (string, error) {
+	post, err := s.visiblePost(postID, viewerID)
+	if err != nil {
+		return "", err
+	}
+	fs, err := s.storage.ForUser(post.UserID)
+	if err != nil {
+		return "", err
+	}
+	return storage.URL(fs, post.ContentPath, ttl)
 }
 
-func (s *postService) Search(query string, page, limit int) ([]*model.Post, error) {
+func (s *postService) List(filter model.PostFilter, cursor string, limit int, withTotal bool) (*model.PostPage, error) {
+	limit = pageLimit(limit)
+	page, err := parsePage(cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.postStore.List(filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newPostPage(posts, page, limit)
+	if withTotal {
+		total, err := s.postStore.Count(filter)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+	return result, nil
+}
+
+func (s *postService) ListOwn(userID, page, limit int) ([]*model.Post, error) {
+	limit = pageLimit(limit)
 	offset := (page - 1) * limit
-	return s.postStore.Search(query, limit, offset)
+	return s.postStore.ListByUser(userID, limit, offset)
 }
 
-func (s *postService) Update(postID int, title, subTitle, image string, tags []string, content string, userID int) (*model.Post, error) {
-	// TODO: This entire operation should be in a single database transaction.
+func (s *postService) Search(query, cursor string, limit int, withTotal bool) (*model.PostPage, error) {
+	limit = pageLimit(limit)
+	page, err := parsePage(cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.postStore.Search(query, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newPostPage(posts, page, limit)
+	if withTotal {
+		total, err := s.postStore.CountSearch(query)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+	return result, nil
+}
+
+// GetHistory lists the archived versions of a post, newest first.
+// The current version lives on the post itself and is not included.
+func (s *postService) GetHistory(postID, viewerID int) ([]*model.PostHistory, error) {
+	if _, err := s.visiblePost(postID, viewerID); err != nil {
+		return nil, err
+	}
+	return s.postStore.ListHistory(postID)
+}
+
+// GetVersion returns a single version of a post together with its markdown.
+// Asking for the current version is allowed so callers don't need to care
+// whether a version has been archived yet.
+func (s *postService) GetVersion(postID, version, viewerID int) (*model.PostHistory, string, error) {
+	post, err := s.visiblePost(postID, viewerID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var history *model.PostHistory
+	if version == post.Version {
+		history = snapshot(post)
+		history.CreatedAt = post.UpdatedAt
+	} else {
+		history, err = s.postStore.GetHistory(postID, version)
+		if err != nil {
+			return nil, "", ErrNotFound
+		}
+	}
+
+	content, err := s.readContent(post.UserID, history.ContentPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read content for post %d version %d: %w", postID, version, err)
+	}
+
+	return history, string(content), nil
+}
+
+// Diff compares the markdown of two versions of a post line by line.
+func (s *postService) Diff(postID, fromVersion, toVersion, viewerID int) (*model.PostDiff, error) {
+	fromHistory, from, err := s.GetVersion(postID, fromVersion, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	toHistory, to, err := s.GetVersion(postID, toVersion, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	hunks := diff.Compute(from, to, diff.DefaultContext)
+	if hunks == nil {
+		hunks = []diff.Hunk{}
+	}
 
+	return &model.PostDiff{
+		PostID:      postID,
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		Metadata:    metadataChanges(fromHistory, toHistory),
+		Unified:     diff.Unified(fmt.Sprintf("v%d", fromVersion), fmt.Sprintf("v%d", toVersion), hunks),
+		Hunks:       hunks,
+	}, nil
+}
+
+func (s *postService) Update(postID int, title, subTitle, image string, tags []string, content string, userID int) (*model.Post, error) {
 	// 1. Get the current post from the database.
 	post, err := s.postStore.GetByID(postID)
 	if err != nil {
@@ -126,35 +310,266 @@ func (s *postService) Update(postID int, title, subTitle, image string, tags []s
 		return nil, ErrPermissionDenied
 	}
 
-	// 3. Create a history record for the *current* version before we update it.
-	history := &model.PostHistory{
-		PostID:      post.ID,
-		Version:     post.Version,
-		ContentPath: post.ContentPath,
+	return s.saveNewVersion(post, title, subTitle, image, tags, []byte(content))
+}
+
+// Restore makes a new version of a post whose content is copied from an
+// earlier, archived version. The version being replaced is archived first,
+// exactly as Update does, so a restore can itself be undone.
+func (s *postService) Restore(postID, version, userID int) (*model.Post, error) {
+	post, err := s.postStore.GetByID(postID)
+	if err != nil {
+		return nil, ErrNotFound
 	}
-	if err := s.postStore.CreateHistory(history); err != nil {
-		return nil, fmt.Errorf("failed to create post history: %w", err)
+
+	if post.UserID != userID {
+		return nil, ErrPermissionDenied
 	}
 
-	// 4. Increment version and define the new content path.
+	history, err := s.postStore.GetHistory(postID, version)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	content, err := s.readContent(post.UserID, history.ContentPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read content for post %d version %d: %w", postID, version, err)
+	}
+
+	// Versions archived before metadata snapshots existed only restore content.
+	if !history.HasMetadata() {
+		return s.saveNewVersion(post, post.Title, post.SubTitle, post.Image, post.Tags, content)
+	}
+	return s.saveNewVersion(post, history.Title, history.SubTitle, history.Image, history.Tags, content)
+}
+
+// saveNewVersion archives the current version of post and replaces it with
+// a new version built from the given metadata and content. The history row
+// and the post update share a transaction, and the new content file is
+// removed again if that transaction does not commit.
+func (s *postService) saveNewVersion(post *model.Post, title, subTitle, image string, tags []string, content []byte) (*model.Post, error) {
 	newVersion := post.Version + 1
-	newContentPath := fmt.Sprintf("user_%d/post_%d_v%d.md", post.UserID, post.ID, newVersion)
-
-	// 5. Save the new content to file storage.
-	if err := s.fileStorage.Save(newContentPath, []byte(content)); err != nil {
-		// If this fails, we have a history record but haven't updated the main post.
-		// A transaction would allow us to roll back the history creation.
-		return nil, fmt.Errorf("failed to save new post content: %w", err)
-	}
-
-	// 6. Update the post model with new data.
-	post.Title = title
-	post.SubTitle = subTitle
-	post.Image = image
-	post.Tags = tags
-	post.Version = newVersion
-	post.ContentPath = newContentPath
-
-	// 7. Persist the updated post to the database.
-	return s.postStore.Update(post)
-}
\ No newline at end of file
+	newContentPath := s.layout.contentPath(post.UserID, post.ID, newVersion, content)
+	// Edits that don't change what the title reads as keep their URL.
+	renamed := post.Slug == "" || slug.Make(title) != slug.Make(post.Title)
+
+	fs, err := s.storage.ForUser(post.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var updatedPost *model.Post
+	var savedPath string
+	err = s.uow.Do(func(posts store.PostStore) error {
+		// 1. Create a history record for the *current* version before we update it.
+		// The unique (post_id, version) constraint makes a concurrent update of
+		// the same version fail here, before it can touch any files.
+		if err := posts.CreateHistory(snapshot(post)); err != nil {
+			return fmt.Errorf("failed to create post history: %w", err)
+		}
+
+		// 2. Save the new content to file storage.
+		savedPath = newContentPath
+		if err := fs.Save(newContentPath, content); err != nil {
+			return fmt.Errorf("failed to save new post content: %w", err)
+		}
+
+		// 3. Rename the post if its title changed. The old slug keeps
+		// redirecting here.
+		if renamed {
+			newSlug, err := assignSlug(posts, title, post.ID, post.Slug)
+			if err != nil {
+				return fmt.Errorf("failed to rename post: %w", err)
+			}
+			post.Slug = newSlug
+		}
+
+		// 4. Update the post model with new data.
+		post.Title = title
+		post.SubTitle = subTitle
+		post.Image = image
+		post.Tags = tags
+		post.Version = newVersion
+		post.ContentPath = newContentPath
+
+		// 5. Persist the updated post to the database.
+		var err error
+		updatedPost, err = posts.Update(post)
+		return err
+	})
+	if err != nil {
+		s.discard(fs, savedPath)
+		return nil, err
+	}
+
+	return updatedPost, nil
+}
+
+func (s *postService) Delete(postID, userID int) error {
+	post, err := s.postStore.GetByID(postID)
+	if err != nil {
+		return ErrNotFound
+	}
+	if post.UserID != userID {
+		return ErrPermissionDenied
+	}
+	return s.postStore.SoftDelete(postID)
+}
+
+func (s *postService) SetStatus(postID, userID int, status model.PostStatus, publishAt *time.Time) (*model.Post, error) {
+	post, err := s.postStore.GetByID(postID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if post.UserID != userID {
+		return nil, ErrPermissionDenied
+	}
+
+	publishedAt, err := publishTime(status, publishAt, post.PublishedAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.postStore.SetStatus(postID, status, publishedAt); err != nil {
+		return nil, err
+	}
+	post.Status = status
+	post.PublishedAt = publishedAt
+	return post, nil
+}
+
+func (s *postService) Trash(userID int) ([]*model.Post, error) {
+	return s.postStore.ListDeleted(userID)
+}
+
+func (s *postService) Undelete(postID, userID int) (*model.Post, error) {
+	post, err := s.postStore.GetDeleted(postID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if post.UserID != userID {
+		return nil, ErrPermissionDenied
+	}
+	if err := s.postStore.Undelete(postID); err != nil {
+		return nil, err
+	}
+	post.DeletedAt = nil
+	return post, nil
+}
+
+func (s *postService) ResolveSlug(name string, viewerID int) (int, string, error) {
+	id, err := s.postStore.GetIDBySlug(name)
+	if err != nil {
+		return 0, "", ErrNotFound
+	}
+	post, err := s.visiblePost(id, viewerID)
+	if err != nil {
+		return 0, "", err
+	}
+	return post.ID, post.Slug, nil
+}
+
+// visiblePost returns a post if viewerID may see it. Posts that are not
+// public are reported as not found to anyone but their owner, so their
+// existence isn't given away.
+func (s *postService) visiblePost(postID, viewerID int) (*model.Post, error) {
+	post, err := s.postStore.GetByID(postID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if post.UserID != viewerID && !post.IsPublic(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return post, nil
+}
+
+// publishTime validates a status change and works out the post's new
+// PublishedAt. current is the post's PublishedAt before the change, so a
+// post that is published again, or archived, keeps the time it first went
+// live.
+func publishTime(status model.PostStatus, publishAt, current *time.Time, now time.Time) (*time.Time, error) {
+	if publishAt != nil && status != model.StatusScheduled {
+		return nil, fmt.Errorf("%w: a publish time is only allowed for scheduled posts", ErrInvalidStatus)
+	}
+
+	switch status {
+	case model.StatusPublished:
+		if current != nil && !current.After(now) {
+			return current, nil
+		}
+		return &now, nil
+	case model.StatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return nil, fmt.Errorf("%w: scheduled posts need a publish time in the future", ErrInvalidStatus)
+		}
+		return publishAt, nil
+	case model.StatusDraft:
+		return nil, nil
+	case model.StatusArchived:
+		return current, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidStatus, status)
+	}
+}
+
+// readContent reads a content file from the storage of the user who owns it.
+func (s *postService) readContent(userID int, path string) ([]byte, error) {
+	fs, err := s.storage.ForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Read(path)
+}
+
+// discard removes a content file written by a post write that did not
+// commit. Failures are only logged: the file is unreferenced either way.
+// Blobs are left alone since other versions may share them; the content
+// GC removes them once nothing references them.
+func (s *postService) discard(fs storage.FileStorage, path string) {
+	if path == "" || blobPattern.MatchString(path) {
+		return
+	}
+	if err := fs.Delete(path); err != nil {
+		log.Printf("could not remove orphaned content file %s: %v", path, err)
+	}
+}
+
+// snapshot captures the current version of a post as a history record.
+// Only the owner can edit a post, so the owner is the author of every version.
+func snapshot(post *model.Post) *model.PostHistory {
+	return &model.PostHistory{
+		PostID:           post.ID,
+		Version:          post.Version,
+		Title:            post.Title,
+		SubTitle:         post.SubTitle,
+		Image:            post.Image,
+		Tags:             post.Tags,
+		AuthorID:         post.UserID,
+		ContentPath:      post.ContentPath,
+		MetadataCaptured: true,
+	}
+}
+
+// metadataChanges lists the metadata fields that differ between two versions.
+// Nothing is reported when either side predates metadata snapshots.
+func metadataChanges(from, to *model.PostHistory) []model.FieldChange {
+	changes := []model.FieldChange{}
+	if !from.HasMetadata() || !to.HasMetadata() {
+		return changes
+	}
+
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"title", from.Title, to.Title},
+		{"sub_title", from.SubTitle, to.SubTitle},
+		{"image", from.Image, to.Image},
+		{"tags", strings.Join(from.Tags, ", "), strings.Join(to.Tags, ", ")},
+	}
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, model.FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
@@ -1,9 +1,17 @@
 package service_test
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"go-blog/internal/model"
 	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"go-blog/internal/store"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,6 +37,10 @@ func (m *MockPostStore) Update(post *model.Post) (*model.Post, error) {
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
+	// Tests can return a func to echo back the post being saved.
+	if fn, ok := args.Get(0).(func(*model.Post) *model.Post); ok {
+		return fn(post), args.Error(1)
+	}
 	return args.Get(0).(*model.Post), args.Error(1)
 }
 
@@ -40,27 +52,163 @@ func (m *MockPostStore) GetByID(id int) (*model.Post, error) {
 	return args.Get(0).(*model.Post), args.Error(1)
 }
 
-func (m *MockPostStore) List(limit, offset int) ([]*model.Post, error) {
-	args := m.Called(limit, offset)
+func (m *MockPostStore) List(filter model.PostFilter, page store.Page) ([]*model.Post, error) {
+	args := m.Called(filter, page)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*model.Post), args.Error(1)
 }
 
+func (m *MockPostStore) Count(filter model.PostFilter) (int, error) {
+	args := m.Called(filter)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *MockPostStore) CreateHistory(history *model.PostHistory) error {
 	args := m.Called(history)
 	return args.Error(0)
 }
 
-func (m *MockPostStore) Search(query string, limit, offset int) ([]*model.Post, error) {
-	args := m.Called(query, limit, offset)
+func (m *MockPostStore) ListHistory(postID int) ([]*model.PostHistory, error) {
+	args := m.Called(postID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.PostHistory), args.Error(1)
+}
+
+func (m *MockPostStore) GetHistory(postID, version int) (*model.PostHistory, error) {
+	args := m.Called(postID, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PostHistory), args.Error(1)
+}
+
+func (m *MockPostStore) ListContentPaths() ([]string, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockPostStore) ReplaceContentPath(oldPath, newPath string) error {
+	args := m.Called(oldPath, newPath)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) SoftDelete(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) Undelete(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) GetDeleted(id int) (*model.Post, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Post), args.Error(1)
+}
+
+func (m *MockPostStore) GetIDBySlug(slug string) (int, error) {
+	args := m.Called(slug)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostStore) SlugTaken(slug string, postID int) (bool, error) {
+	args := m.Called(slug, postID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPostStore) SetSlug(id int, slug string) error {
+	args := m.Called(id, slug)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) ListWithoutSlug() ([]*model.Post, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Post), args.Error(1)
+}
+
+func (m *MockPostStore) ListByUser(userID, limit, offset int) ([]*model.Post, error) {
+	args := m.Called(userID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Post), args.Error(1)
+}
+
+func (m *MockPostStore) SetStatus(id int, status model.PostStatus, publishedAt *time.Time) error {
+	args := m.Called(id, status, publishedAt)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) PublishDue(now time.Time) (int, error) {
+	args := m.Called(now)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockPostStore) ListDeleted(userID int) ([]*model.Post, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Post), args.Error(1)
+}
+
+func (m *MockPostStore) ListDeletedBefore(cutoff time.Time) ([]*model.Post, error) {
+	args := m.Called(cutoff)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).([]*model.Post), args.Error(1)
 }
 
+func (m *MockPostStore) Purge(id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockPostStore) Search(query string, page store.Page) ([]*model.Post, error) {
+	args := m.Called(query, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Post), args.Error(1)
+}
+
+func (m *MockPostStore) CountSearch(query string) (int, error) {
+	args := m.Called(query)
+	return args.Int(0), args.Error(1)
+}
+
+// MockUnitOfWork runs the unit of work against the mock store and records
+// whether it would have been committed or rolled back.
+type MockUnitOfWork struct {
+	posts      store.PostStore
+	Committed  int
+	RolledBack int
+}
+
+func (u *MockUnitOfWork) Do(fn func(posts store.PostStore) error) error {
+	if err := fn(u.posts); err != nil {
+		u.RolledBack++
+		return err
+	}
+	u.Committed++
+	return nil
+}
+
 // MockFileStorage is a mock implementation of storage.FileStorage
 type MockFileStorage struct {
 	mock.Mock
@@ -79,10 +227,59 @@ func (m *MockFileStorage) Read(path string) ([]byte, error) {
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (m *MockFileStorage) SaveStream(path string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	args := m.Called(path, data)
+	return args.Error(0)
+}
+
+func (m *MockFileStorage) ReadStream(path string) (io.ReadCloser, error) {
+	args := m.Called(path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return io.NopCloser(bytes.NewReader(args.Get(0).([]byte))), args.Error(1)
+}
+
+func (m *MockFileStorage) Delete(path string) error {
+	args := m.Called(path)
+	return args.Error(0)
+}
+
+func (m *MockFileStorage) Exists(path string) (bool, error) {
+	args := m.Called(path)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockFileStorage) Stat(path string) (*storage.FileInfo, error) {
+	args := m.Called(path)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*storage.FileInfo), args.Error(1)
+}
+
+func (m *MockFileStorage) List(prefix string) ([]storage.FileInfo, error) {
+	args := m.Called(prefix)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]storage.FileInfo), args.Error(1)
+}
+
+// allowSlugs lets tests that don't care about slugs name posts freely.
+func allowSlugs(m *MockPostStore) {
+	m.On("SlugTaken", mock.Anything, mock.Anything).Return(false, nil).Maybe()
+	m.On("SetSlug", mock.Anything, mock.Anything).Return(nil).Maybe()
+}
+
 func TestPostService_Create(t *testing.T) {
 	mockPostStore := new(MockPostStore)
 	mockFileStorage := new(MockFileStorage)
-	postSvc := service.NewPostService(mockPostStore, mockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
 
 	userID := 1
 	title := "Test Title"
@@ -100,6 +297,7 @@ func TestPostService_Create(t *testing.T) {
 		Image:    image,
 		Tags:     tags,
 		Version:  1,
+		Status:   model.StatusDraft,
 	}
 
 	// The post model returned by the first Create call, now with an ID
@@ -120,6 +318,7 @@ func TestPostService_Create(t *testing.T) {
 		ID:          1,
 		UserID:      userID,
 		Title:       title,
+		Slug:        "test-title",
 		SubTitle:    subTitle,
 		Image:       image,
 		Tags:        tags,
@@ -129,12 +328,14 @@ func TestPostService_Create(t *testing.T) {
 
 	// Setup mock expectations
 	mockPostStore.On("Create", initialPost).Return(createdPostWithID, nil).Once()
+	mockPostStore.On("SlugTaken", "test-title", 1).Return(false, nil).Once()
+	mockPostStore.On("SetSlug", 1, "test-title").Return(nil).Once()
 	mockFileStorage.On("Save", contentPath, []byte(content)).Return(nil).Once()
 	mockPostStore.On("Update", finalPost).Return(finalPost, nil).Once()
 
 	// Execute the service method
 	// Execute the service method
-	post, err := postSvc.Create(title, subTitle, image, tags, content, userID)
+	post, err := postSvc.Create(title, subTitle, image, tags, content, model.StatusDraft, nil, userID)
 
 	// Assertions
 	assert.NoError(t, err)
@@ -146,10 +347,141 @@ func TestPostService_Create(t *testing.T) {
 	mockFileStorage.AssertExpectations(t)
 }
 
+func TestPostService_Create_SaveFails(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	uow := &MockUnitOfWork{posts: mockPostStore}
+	postSvc := service.NewPostService(mockPostStore, uow, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	createdPostWithID := &model.Post{ID: 1, UserID: 1, Title: "Test Title", Version: 1}
+	saveErr := errors.New("s3 unavailable")
+
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(createdPostWithID, nil).Once()
+	mockFileStorage.On("Save", "user_1/post_1_v1.md", []byte("content")).Return(saveErr).Once()
+	// A partially written file must not be left behind.
+	mockFileStorage.On("Delete", "user_1/post_1_v1.md").Return(nil).Once()
+
+	post, err := postSvc.Create("Test Title", "", "", nil, "content", "", nil, 1)
+
+	assert.ErrorIs(t, err, saveErr)
+	assert.Nil(t, post)
+	assert.Equal(t, 1, uow.RolledBack)
+	assert.Equal(t, 0, uow.Committed)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_CreateFromFile(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	createdPostWithID := &model.Post{ID: 1, UserID: 1, Title: "Uploaded", Version: 1}
+	content := "# Uploaded\n\nStreamed from a file."
+
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(createdPostWithID, nil).Once()
+	mockFileStorage.On("SaveStream", "user_1/post_1_v1.md", []byte(content)).Return(nil).Once()
+	mockPostStore.On("Update", mock.MatchedBy(func(p *model.Post) bool {
+		return p.ContentPath == "user_1/post_1_v1.md"
+	})).Return(createdPostWithID, nil).Once()
+
+	post, err := postSvc.CreateFromFile("Uploaded", "", "", nil, strings.NewReader(content), "", nil, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, createdPostWithID, post)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_WithMemoryStorage(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
+	fileStorage := storage.NewMemoryStorage()
+	uow := &MockUnitOfWork{posts: mockPostStore}
+	postSvc := service.NewPostService(mockPostStore, uow, storage.NewSharedResolver(fileStorage), service.LayoutVersioned)
+
+	post := &model.Post{ID: 1, UserID: 1, Title: "Title", Version: 1}
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(post, nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(post, nil).Once()
+
+	created, err := postSvc.Create("Title", "", "", nil, "first draft", "", nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "user_1/post_1_v1.md", created.ContentPath)
+
+	// The next save fails half way through an update.
+	fileStorage.FailNth(storage.OpSave, 1, errors.New("bucket unavailable"))
+	mockPostStore.On("GetByID", 1).Return(created, nil).Once()
+	mockPostStore.On("CreateHistory", mock.AnythingOfType("*model.PostHistory")).Return(nil).Once()
+
+	_, err = postSvc.Update(1, "Title", "", "", nil, "second draft", 1)
+	assert.Error(t, err)
+	assert.Equal(t, 1, uow.RolledBack)
+
+	// Only the first version is left in storage.
+	files, err := fileStorage.List("user_1/")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	content, err := fileStorage.Read("user_1/post_1_v1.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "first draft", string(content))
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_ContentAddressedLayout(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
+	fileStorage := storage.NewMemoryStorage()
+	uow := &MockUnitOfWork{posts: mockPostStore}
+	postSvc := service.NewPostService(mockPostStore, uow, storage.NewSharedResolver(fileStorage), service.LayoutCAS)
+
+	sum := sha256.Sum256([]byte("same content"))
+	blob := "user_1/blobs/" + hex.EncodeToString(sum[:]) + ".md"
+
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(&model.Post{ID: 1, UserID: 1, Version: 1}, nil).Once()
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(&model.Post{ID: 2, UserID: 1, Version: 1}, nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(func(p *model.Post) *model.Post { return p }, nil)
+
+	// An imported post with the same content shares the blob.
+	first, err := postSvc.Create("First", "", "", nil, "same content", "", nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, blob, first.ContentPath)
+	second, err := postSvc.CreateFromFile("Second", "", "", nil, strings.NewReader("same content"), "", nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, blob, second.ContentPath)
+
+	// So does a new version that only changes the title.
+	mockPostStore.On("GetByID", 1).Return(first, nil).Once()
+	mockPostStore.On("CreateHistory", mock.MatchedBy(func(h *model.PostHistory) bool {
+		return h.ContentPath == blob
+	})).Return(nil).Once()
+	updated, err := postSvc.Update(1, "Renamed", "", "", nil, "same content", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+	assert.Equal(t, blob, updated.ContentPath)
+
+	files, err := fileStorage.List("user_1/")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// A failed write must not remove a blob other versions still use.
+	mockPostStore.On("GetByID", 1).Return(updated, nil).Once()
+	mockPostStore.On("CreateHistory", mock.AnythingOfType("*model.PostHistory")).Return(errors.New("conflict")).Once()
+	_, err = postSvc.Update(1, "Renamed again", "", "", nil, "same content", 1)
+	assert.Error(t, err)
+	exists, err := fileStorage.Exists(blob)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
 func TestPostService_GetByID(t *testing.T) {
 	mockPostStore := new(MockPostStore)
 	mockFileStorage := new(MockFileStorage)
-	postSvc := service.NewPostService(mockPostStore, mockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
 
 	postID := 1
 	contentPath := "user_1/post_1_v1.md"
@@ -161,6 +493,7 @@ func TestPostService_GetByID(t *testing.T) {
 		Title:       "A Post",
 		ContentPath: contentPath,
 		Version:     1,
+		Status:      model.StatusPublished,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
@@ -170,7 +503,7 @@ func TestPostService_GetByID(t *testing.T) {
 	mockFileStorage.On("Read", contentPath).Return([]byte(content), nil).Once()
 
 	// Execute
-	post, postContent, err := postSvc.GetByID(postID)
+	post, postContent, err := postSvc.GetByID(postID, 0)
 
 	// Assertions
 	assert.NoError(t, err)
@@ -184,8 +517,9 @@ func TestPostService_GetByID(t *testing.T) {
 
 func TestPostService_Update(t *testing.T) {
 	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
 	mockFileStorage := new(MockFileStorage)
-	postSvc := service.NewPostService(mockPostStore, mockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
 
 	postID := 1
 	userID := 1
@@ -210,7 +544,10 @@ func TestPostService_Update(t *testing.T) {
 
 	// Setup mock expectations
 	mockPostStore.On("GetByID", postID).Return(currentPost, nil).Once()
-	mockPostStore.On("CreateHistory", mock.AnythingOfType("*model.PostHistory")).Return(nil).Once()
+	// The archived version carries a snapshot of the metadata being replaced.
+	mockPostStore.On("CreateHistory", mock.MatchedBy(func(h *model.PostHistory) bool {
+		return h.Version == originalVersion && h.Title == "Original Title" && h.AuthorID == userID
+	})).Return(nil).Once()
 	mockFileStorage.On("Save", newContentPath, []byte(newContent)).Return(nil).Once()
 	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(currentPost, nil).Once()
 
@@ -230,4 +567,341 @@ func TestPostService_Update(t *testing.T) {
 
 	mockPostStore.AssertExpectations(t)
 	mockFileStorage.AssertExpectations(t)
-}
\ No newline at end of file
+}
+
+func TestPostService_Update_RollsBackOnStoreError(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	uow := &MockUnitOfWork{posts: mockPostStore}
+	postSvc := service.NewPostService(mockPostStore, uow, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	currentPost := &model.Post{ID: 1, UserID: 1, Title: "Title", ContentPath: "user_1/post_1_v1.md", Version: 1}
+	dbErr := errors.New("connection reset")
+
+	mockPostStore.On("GetByID", 1).Return(currentPost, nil).Once()
+	mockPostStore.On("CreateHistory", mock.AnythingOfType("*model.PostHistory")).Return(nil).Once()
+	mockFileStorage.On("Save", "user_1/post_1_v2.md", []byte("new content")).Return(nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(nil, dbErr).Once()
+	// The new version's file is removed because the transaction rolled back.
+	mockFileStorage.On("Delete", "user_1/post_1_v2.md").Return(nil).Once()
+
+	post, err := postSvc.Update(1, "Title", "", "", nil, "new content", 1)
+
+	assert.ErrorIs(t, err, dbErr)
+	assert.Nil(t, post)
+	assert.Equal(t, 1, uow.RolledBack)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_GetVersion(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	postID := 1
+	currentPost := &model.Post{
+		ID:          postID,
+		UserID:      1,
+		ContentPath: "user_1/post_1_v3.md",
+		Version:     3,
+	}
+	archived := &model.PostHistory{
+		ID:          7,
+		PostID:      postID,
+		Version:     2,
+		ContentPath: "user_1/post_1_v2.md",
+	}
+
+	mockPostStore.On("GetByID", postID).Return(currentPost, nil)
+	mockPostStore.On("GetHistory", postID, 2).Return(archived, nil).Once()
+	mockPostStore.On("GetHistory", postID, 9).Return(nil, errors.New("sql: no rows in result set")).Once()
+	mockFileStorage.On("Read", "user_1/post_1_v2.md").Return([]byte("old content"), nil).Once()
+	mockFileStorage.On("Read", "user_1/post_1_v3.md").Return([]byte("current content"), nil).Once()
+
+	// An archived version is read from its history record.
+	history, content, err := postSvc.GetVersion(postID, 2, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, archived, history)
+	assert.Equal(t, "old content", content)
+
+	// The current version is served from the post itself.
+	history, content, err = postSvc.GetVersion(postID, 3, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, history.Version)
+	assert.Equal(t, "current content", content)
+
+	// Unknown versions are reported as not found.
+	_, _, err = postSvc.GetVersion(postID, 9, 1)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_Diff(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	postID := 1
+	currentPost := &model.Post{ID: postID, UserID: 1, Title: "New Title", ContentPath: "user_1/post_1_v2.md", Version: 2}
+	archived := &model.PostHistory{PostID: postID, Version: 1, Title: "Old Title", ContentPath: "user_1/post_1_v1.md", MetadataCaptured: true}
+
+	mockPostStore.On("GetByID", postID).Return(currentPost, nil)
+	mockPostStore.On("GetHistory", postID, 1).Return(archived, nil).Once()
+	mockFileStorage.On("Read", "user_1/post_1_v1.md").Return([]byte("# Title\nold line\n"), nil).Once()
+	mockFileStorage.On("Read", "user_1/post_1_v2.md").Return([]byte("# Title\nnew line\n"), nil).Once()
+
+	postDiff, err := postSvc.Diff(postID, 1, 2, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, postDiff.FromVersion)
+	assert.Equal(t, 2, postDiff.ToVersion)
+	assert.Len(t, postDiff.Hunks, 1)
+	assert.Contains(t, postDiff.Unified, "-old line\n+new line\n")
+	assert.Equal(t, []model.FieldChange{{Field: "title", From: "Old Title", To: "New Title"}}, postDiff.Metadata)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_Restore(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	postID := 1
+	userID := 1
+	currentPost := &model.Post{
+		ID:          postID,
+		UserID:      userID,
+		Title:       "Current Title",
+		ContentPath: "user_1/post_1_v3.md",
+		Version:     3,
+	}
+	archived := &model.PostHistory{
+		PostID:           postID,
+		Version:          1,
+		Title:            "Original Title",
+		Tags:             []string{"go"},
+		ContentPath:      "user_1/post_1_v1.md",
+		MetadataCaptured: true,
+	}
+	oldContent := []byte("original content")
+
+	mockPostStore.On("GetByID", postID).Return(currentPost, nil).Once()
+	mockPostStore.On("GetHistory", postID, 1).Return(archived, nil).Once()
+	mockFileStorage.On("Read", "user_1/post_1_v1.md").Return(oldContent, nil).Once()
+	// The current version must be archived before it is replaced.
+	mockPostStore.On("CreateHistory", mock.MatchedBy(func(h *model.PostHistory) bool {
+		return h.Version == 3 && h.ContentPath == "user_1/post_1_v3.md"
+	})).Return(nil).Once()
+	mockFileStorage.On("Save", "user_1/post_1_v4.md", oldContent).Return(nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(currentPost, nil).Once()
+
+	restored, err := postSvc.Restore(postID, 1, userID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, restored.Version)
+	assert.Equal(t, "user_1/post_1_v4.md", restored.ContentPath)
+	assert.Equal(t, "Original Title", restored.Title)
+	assert.Equal(t, []string{"go"}, restored.Tags)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_Restore_WithoutSnapshot(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	currentPost := &model.Post{ID: 1, UserID: 1, Title: "Current Title", Tags: []string{"go"}, ContentPath: "user_1/post_1_v2.md", Version: 2}
+	// Archived before metadata snapshots existed, so only the content is restored.
+	archived := &model.PostHistory{PostID: 1, Version: 1, ContentPath: "user_1/post_1_v1.md"}
+
+	mockPostStore.On("GetByID", 1).Return(currentPost, nil).Once()
+	mockPostStore.On("GetHistory", 1, 1).Return(archived, nil).Once()
+	mockFileStorage.On("Read", "user_1/post_1_v1.md").Return([]byte("original content"), nil).Once()
+	mockPostStore.On("CreateHistory", mock.AnythingOfType("*model.PostHistory")).Return(nil).Once()
+	mockFileStorage.On("Save", "user_1/post_1_v3.md", []byte("original content")).Return(nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(currentPost, nil).Once()
+
+	restored, err := postSvc.Restore(1, 1, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Current Title", restored.Title)
+	assert.Equal(t, []string{"go"}, restored.Tags)
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_Restore_PermissionDenied(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	currentPost := &model.Post{ID: 1, UserID: 1, Version: 2}
+	mockPostStore.On("GetByID", 1).Return(currentPost, nil).Once()
+
+	restored, err := postSvc.Restore(1, 1, 2)
+
+	assert.ErrorIs(t, err, service.ErrPermissionDenied)
+	assert.Nil(t, restored)
+
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_ContentURL(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	local, err := storage.NewLocalStorage(t.TempDir())
+	assert.NoError(t, err)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(local), service.LayoutVersioned)
+
+	post := &model.Post{ID: 1, UserID: 1, Version: 2, ContentPath: "user_1/post_1_v2.md"}
+	mockPostStore.On("GetByID", 1).Return(post, nil)
+
+	// Without a signer the caller has to stream the content itself.
+	_, err = postSvc.ContentURL(1, time.Minute, 1)
+	assert.ErrorIs(t, err, storage.ErrURLNotSupported)
+
+	local.SignURLs(storage.NewHMACSigner("/files/", []byte("secret")))
+	url, err := postSvc.ContentURL(1, time.Minute, 1)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(url, "/files/user_1/post_1_v2.md?"))
+}
+
+
+func TestPostService_Delete(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	mockPostStore.On("GetByID", 1).Return(&model.Post{ID: 1, UserID: 1}, nil)
+	mockPostStore.On("GetByID", 2).Return(nil, errors.New("sql: no rows in result set"))
+	mockPostStore.On("SoftDelete", 1).Return(nil).Once()
+
+	assert.ErrorIs(t, postSvc.Delete(1, 2), service.ErrPermissionDenied)
+	assert.ErrorIs(t, postSvc.Delete(2, 1), service.ErrNotFound)
+	assert.NoError(t, postSvc.Delete(1, 1))
+
+	// Content stays in storage until the post is purged.
+	mockPostStore.AssertExpectations(t)
+	mockFileStorage.AssertExpectations(t)
+}
+
+func TestPostService_Undelete(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockFileStorage := new(MockFileStorage)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(mockFileStorage), service.LayoutVersioned)
+
+	deletedAt := time.Now()
+	mockPostStore.On("GetDeleted", 1).Return(&model.Post{ID: 1, UserID: 1, DeletedAt: &deletedAt}, nil)
+	mockPostStore.On("GetDeleted", 2).Return(nil, errors.New("sql: no rows in result set"))
+	mockPostStore.On("Undelete", 1).Return(nil).Once()
+
+	_, err := postSvc.Undelete(1, 2)
+	assert.ErrorIs(t, err, service.ErrPermissionDenied)
+	_, err = postSvc.Undelete(2, 1)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+
+	post, err := postSvc.Undelete(1, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, post.DeletedAt)
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_Create_Scheduled(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	allowSlugs(mockPostStore)
+	fileStorage := storage.NewMemoryStorage()
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(fileStorage), service.LayoutVersioned)
+
+	publishAt := time.Now().Add(time.Hour)
+	mockPostStore.On("Create", mock.MatchedBy(func(p *model.Post) bool {
+		return p.Status == model.StatusScheduled && p.PublishedAt.Equal(publishAt)
+	})).Return(&model.Post{ID: 1, UserID: 1, Version: 1}, nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(func(p *model.Post) *model.Post { return p }, nil).Once()
+
+	_, err := postSvc.Create("Later", "", "", nil, "content", model.StatusScheduled, &publishAt, 1)
+	assert.NoError(t, err)
+
+	// A schedule has to lie in the future, and only scheduled posts take one.
+	past := time.Now().Add(-time.Hour)
+	_, err = postSvc.Create("Later", "", "", nil, "content", model.StatusScheduled, &past, 1)
+	assert.ErrorIs(t, err, service.ErrInvalidStatus)
+	_, err = postSvc.Create("Later", "", "", nil, "content", model.StatusScheduled, nil, 1)
+	assert.ErrorIs(t, err, service.ErrInvalidStatus)
+	_, err = postSvc.Create("Later", "", "", nil, "content", model.StatusDraft, &publishAt, 1)
+	assert.ErrorIs(t, err, service.ErrInvalidStatus)
+	_, err = postSvc.Create("Later", "", "", nil, "content", "pending", nil, 1)
+	assert.ErrorIs(t, err, service.ErrInvalidStatus)
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_GetByID_Draft(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	fileStorage := storage.NewMemoryStorage()
+	fileStorage.Save("user_1/post_1_v1.md", []byte("work in progress"))
+	fileStorage.Save("user_1/post_2_v1.md", []byte("coming soon"))
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(fileStorage), service.LayoutVersioned)
+
+	draft := &model.Post{ID: 1, UserID: 1, Version: 1, ContentPath: "user_1/post_1_v1.md", Status: model.StatusDraft}
+	due := time.Now().Add(-time.Minute)
+	scheduled := &model.Post{ID: 2, UserID: 1, Version: 1, ContentPath: "user_1/post_2_v1.md", Status: model.StatusScheduled, PublishedAt: &due}
+	mockPostStore.On("GetByID", 1).Return(draft, nil)
+	mockPostStore.On("GetByID", 2).Return(scheduled, nil)
+
+	// Drafts are hidden from everyone but their owner.
+	_, _, err := postSvc.GetByID(1, 0)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+	_, _, err = postSvc.GetByID(1, 2)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+	_, err = postSvc.GetHistory(1, 2)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+
+	_, content, err := postSvc.GetByID(1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "work in progress", content)
+
+	// Scheduled posts are public once due, even before the scheduler ran.
+	_, content, err = postSvc.GetByID(2, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "coming soon", content)
+}
+
+func TestPostService_SetStatus(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	firstPublished := time.Now().Add(-24 * time.Hour)
+	post := &model.Post{ID: 1, UserID: 1, Status: model.StatusPublished, PublishedAt: &firstPublished}
+	mockPostStore.On("GetByID", 1).Return(post, nil)
+
+	// Archiving keeps the time the post went live.
+	mockPostStore.On("SetStatus", 1, model.StatusArchived, &firstPublished).Return(nil).Once()
+	archived, err := postSvc.SetStatus(1, 1, model.StatusArchived, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusArchived, archived.Status)
+	assert.Equal(t, &firstPublished, archived.PublishedAt)
+
+	// Unpublishing clears it.
+	mockPostStore.On("SetStatus", 1, model.StatusDraft, (*time.Time)(nil)).Return(nil).Once()
+	_, err = postSvc.SetStatus(1, 1, model.StatusDraft, nil)
+	assert.NoError(t, err)
+
+	_, err = postSvc.SetStatus(1, 2, model.StatusPublished, nil)
+	assert.ErrorIs(t, err, service.ErrPermissionDenied)
+	_, err = postSvc.SetStatus(1, 1, "", nil)
+	assert.ErrorIs(t, err, service.ErrInvalidStatus)
+
+	mockPostStore.AssertExpectations(t)
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"time"
+
+	"go-blog/internal/store"
+)
+
+// PostScheduler publishes scheduled posts once their time has come.
+//
+// Scheduled posts are already shown from their publish time on, so the
+// scheduler only has to catch up on the status; running it late doesn't
+// delay anything.
+type PostScheduler struct {
+	postStore store.PostStore
+}
+
+// NewPostScheduler creates a PostScheduler.
+func NewPostScheduler(ps store.PostStore) *PostScheduler {
+	return &PostScheduler{postStore: ps}
+}
+
+// PublishDue marks every scheduled post that is due as published and
+// returns how many there were.
+func (s *PostScheduler) PublishDue() (int, error) {
+	return s.postStore.PublishDue(time.Now())
+}
@@ -0,0 +1,23 @@
+package service_test
+
+import (
+	"go-blog/internal/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostScheduler_PublishDue(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockPostStore.On("PublishDue", mock.MatchedBy(func(now time.Time) bool {
+		return time.Since(now) < time.Minute
+	})).Return(2, nil).Once()
+
+	n, err := service.NewPostScheduler(mockPostStore).PublishDue()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	mockPostStore.AssertExpectations(t)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-blog/internal/slug"
+	"go-blog/internal/store"
+)
+
+// reservedSlugs are path segments that routes under /posts/ already use.
+var reservedSlugs = map[string]bool{"search": true, "mine": true, "upload": true}
+
+// maxSlugAttempts bounds the numbered suffixes tried before falling back to
+// the post ID, which no other post can have.
+const maxSlugAttempts = 50
+
+// assignSlug gives the post postID titled title a slug that no other post
+// has or had, adding a numeric suffix where needed, and returns it. current
+// is the post's slug so far, which is kept if it is still the first free
+// one. Slugs that are all digits would be read as post IDs, so they get a
+// prefix.
+//
+// Another post may claim a slug between the check and SetSlug; the next
+// candidate is tried then.
+func assignSlug(posts store.PostStore, title string, postID int, current string) (string, error) {
+	base := slug.Make(title)
+	switch {
+	case base == "":
+		// Nothing in the title transliterates, e.g. it is all CJK.
+		base = fmt.Sprintf("post-%d", postID)
+	case strings.Trim(base, "0123456789") == "":
+		base = "post-" + base
+	}
+
+	for n := 1; n <= maxSlugAttempts+1; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		if n > maxSlugAttempts {
+			candidate = fmt.Sprintf("%s-%d", base, postID)
+		}
+		if reservedSlugs[candidate] {
+			continue
+		}
+		taken, err := posts.SlugTaken(candidate, postID)
+		if err != nil {
+			return "", fmt.Errorf("failed to check slug %q: %w", candidate, err)
+		}
+		if taken {
+			continue
+		}
+		if candidate == current {
+			return candidate, nil
+		}
+		err = posts.SetSlug(postID, candidate)
+		if errors.Is(err, store.ErrSlugTaken) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to set slug %q: %w", candidate, err)
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("no free slug for post %d", postID)
+}
+
+// SlugBackfill gives posts created before slugs existed one derived from
+// their title.
+type SlugBackfill struct {
+	postStore store.PostStore
+	uow       store.UnitOfWork
+}
+
+// NewSlugBackfill creates a SlugBackfill.
+func NewSlugBackfill(ps store.PostStore, uow store.UnitOfWork) *SlugBackfill {
+	return &SlugBackfill{postStore: ps, uow: uow}
+}
+
+// Run names every post that has no slug and returns how many it named.
+// Posts are named one at a time, so an interrupted run can simply be
+// started again.
+func (b *SlugBackfill) Run() (int, error) {
+	posts, err := b.postStore.ListWithoutSlug()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list posts without a slug: %w", err)
+	}
+
+	named := 0
+	for _, post := range posts {
+		err := b.uow.Do(func(posts store.PostStore) error {
+			_, err := assignSlug(posts, post.Title, post.ID, "")
+			return err
+		})
+		if err != nil {
+			return named, fmt.Errorf("failed to name post %d: %w", post.ID, err)
+		}
+		named++
+	}
+	return named, nil
+}
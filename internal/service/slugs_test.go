@@ -0,0 +1,142 @@
+package service_test
+
+import (
+	"errors"
+	"go-blog/internal/model"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"go-blog/internal/store"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPostService_Create_UniqueSlug(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(&model.Post{ID: 3, UserID: 1, Version: 1}, nil).Once()
+	mockPostStore.On("SlugTaken", "xin-chao", 3).Return(true, nil).Once()
+	mockPostStore.On("SlugTaken", "xin-chao-2", 3).Return(false, nil).Once()
+	mockPostStore.On("SetSlug", 3, "xin-chao-2").Return(nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(func(p *model.Post) *model.Post { return p }, nil).Once()
+
+	post, err := postSvc.Create("Xin chào", "", "", nil, "content", "", nil, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "xin-chao-2", post.Slug)
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_Create_SlugRace(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(&model.Post{ID: 3, UserID: 1, Version: 1}, nil).Once()
+	mockPostStore.On("SlugTaken", "xin-chao", 3).Return(false, nil).Once()
+	mockPostStore.On("SlugTaken", "xin-chao-2", 3).Return(false, nil).Once()
+	// Another post claims the slug between the check and the write.
+	mockPostStore.On("SetSlug", 3, "xin-chao").Return(store.ErrSlugTaken).Once()
+	mockPostStore.On("SetSlug", 3, "xin-chao-2").Return(nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(func(p *model.Post) *model.Post { return p }, nil).Once()
+
+	post, err := postSvc.Create("Xin chào", "", "", nil, "content", "", nil, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "xin-chao-2", post.Slug)
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_Create_ReservedSlug(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(&model.Post{ID: 1, UserID: 1, Version: 1}, nil).Once()
+	mockPostStore.On("Create", mock.AnythingOfType("*model.Post")).Return(&model.Post{ID: 2, UserID: 1, Version: 1}, nil).Once()
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(func(p *model.Post) *model.Post { return p }, nil)
+	mockPostStore.On("SlugTaken", "search-2", 1).Return(false, nil).Once()
+	mockPostStore.On("SlugTaken", "post-2024", 2).Return(false, nil).Once()
+	mockPostStore.On("SetSlug", mock.Anything, mock.Anything).Return(nil)
+
+	// Slugs must not clash with other routes or read as IDs.
+	post, err := postSvc.Create("Search", "", "", nil, "content", "", nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "search-2", post.Slug)
+
+	post, err = postSvc.Create("2024", "", "", nil, "content", "", nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "post-2024", post.Slug)
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_Update_Renames(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	post := &model.Post{ID: 1, UserID: 1, Title: "Hello World", Slug: "hello-world", Version: 1}
+	mockPostStore.On("GetByID", 1).Return(post, nil)
+	mockPostStore.On("CreateHistory", mock.AnythingOfType("*model.PostHistory")).Return(nil)
+	mockPostStore.On("Update", mock.AnythingOfType("*model.Post")).Return(func(p *model.Post) *model.Post { return p }, nil)
+
+	// Edits that read the same keep the slug.
+	updated, err := postSvc.Update(1, "Hello, world!", "", "", nil, "content", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello-world", updated.Slug)
+	mockPostStore.AssertNotCalled(t, "SetSlug", mock.Anything, mock.Anything)
+
+	mockPostStore.On("SlugTaken", "goodbye-world", 1).Return(false, nil).Once()
+	mockPostStore.On("SetSlug", 1, "goodbye-world").Return(nil).Once()
+
+	updated, err = postSvc.Update(1, "Goodbye World", "", "", nil, "content", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "goodbye-world", updated.Slug)
+
+	mockPostStore.AssertExpectations(t)
+}
+
+func TestPostService_ResolveSlug(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	postSvc := service.NewPostService(mockPostStore, &MockUnitOfWork{posts: mockPostStore}, storage.NewSharedResolver(storage.NewMemoryStorage()), service.LayoutVersioned)
+
+	post := &model.Post{ID: 1, UserID: 1, Slug: "goodbye-world", Status: model.StatusPublished}
+	mockPostStore.On("GetIDBySlug", "goodbye-world").Return(1, nil)
+	mockPostStore.On("GetIDBySlug", "hello-world").Return(1, nil)
+	mockPostStore.On("GetIDBySlug", "nothing").Return(0, errors.New("sql: no rows in result set"))
+	mockPostStore.On("GetByID", 1).Return(post, nil)
+
+	id, current, err := postSvc.ResolveSlug("goodbye-world", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.Equal(t, "goodbye-world", current)
+
+	// Old slugs resolve to the post's current one.
+	id, current, err = postSvc.ResolveSlug("hello-world", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.Equal(t, "goodbye-world", current)
+
+	_, _, err = postSvc.ResolveSlug("nothing", 0)
+	assert.ErrorIs(t, err, service.ErrNotFound)
+}
+
+func TestSlugBackfill_Run(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockPostStore.On("ListWithoutSlug").Return([]*model.Post{
+		{ID: 1, Title: "Tiếng Việt"},
+		{ID: 2, Title: "日本語"},
+	}, nil).Once()
+	mockPostStore.On("SlugTaken", "tieng-viet", 1).Return(false, nil).Once()
+	mockPostStore.On("SlugTaken", "post-2", 2).Return(false, nil).Once()
+	mockPostStore.On("SetSlug", 1, "tieng-viet").Return(nil).Once()
+	mockPostStore.On("SetSlug", 2, "post-2").Return(nil).Once()
+
+	uow := &MockUnitOfWork{posts: mockPostStore}
+	named, err := service.NewSlugBackfill(mockPostStore, uow).Run()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, named)
+	assert.Equal(t, 2, uow.Committed)
+	mockPostStore.AssertExpectations(t)
+}
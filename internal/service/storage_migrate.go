@@ -0,0 +1,175 @@
+package service
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go-blog/internal/storage"
+	"go-blog/internal/store"
+)
+
+// errMissingSource is returned by migrate for files the source doesn't have.
+var errMissingSource = errors.New("file missing from source")
+
+// MigrateReport summarises a copy of stored files between two backends.
+type MigrateReport struct {
+	Total int `json:"total"`
+	// Copied counts files written to the destination, or that would have
+	// been in a dry run.
+	Copied int `json:"copied"`
+	// Skipped counts files the destination already held with the same content.
+	Skipped int `json:"skipped"`
+	// Missing lists referenced files that are not in the source storage.
+	Missing []string `json:"missing"`
+}
+
+// StorageMigrator copies every file the database references from one
+// storage backend to another, e.g. to promote a dataset from local storage
+// to S3.
+type StorageMigrator struct {
+	postStore  store.PostStore
+	mediaStore store.MediaStore
+	from       storage.Resolver
+	to         storage.Resolver
+	dryRun     bool
+}
+
+// NewStorageMigrator creates a StorageMigrator. With dryRun set, files are
+// compared but nothing is written.
+func NewStorageMigrator(ps store.PostStore, ms store.MediaStore, from, to storage.Resolver, dryRun bool) *StorageMigrator {
+	return &StorageMigrator{postStore: ps, mediaStore: ms, from: from, to: to, dryRun: dryRun}
+}
+
+// Run copies the content of every post and archived version, and every
+// media file and variant. Each copy is read back and its SHA-256 compared
+// with the source before it counts as done. Files the destination already
+// holds with the same content are skipped, so an interrupted run is resumed
+// by starting it again. Nothing is removed from the source.
+func (m *StorageMigrator) Run() (*MigrateReport, error) {
+	contentPaths, err := m.postStore.ListContentPaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load referenced content paths: %w", err)
+	}
+	mediaPaths, err := m.mediaStore.ListPaths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load media paths: %w", err)
+	}
+	paths := append(contentPaths, mediaPaths...)
+	sort.Strings(paths)
+
+	report := &MigrateReport{Missing: []string{}}
+	for i, path := range paths {
+		if i > 0 && path == paths[i-1] {
+			continue
+		}
+		report.Total++
+
+		copied, err := m.migrate(path)
+		switch {
+		case errors.Is(err, errMissingSource):
+			report.Missing = append(report.Missing, path)
+		case err != nil:
+			return report, err
+		case copied:
+			report.Copied++
+		default:
+			report.Skipped++
+		}
+	}
+	return report, nil
+}
+
+// migrate copies a single file and reports whether it had to be written.
+func (m *StorageMigrator) migrate(path string) (bool, error) {
+	userID, ok := storage.Owner(path)
+	if !ok {
+		return false, fmt.Errorf("cannot tell the owner of %s", path)
+	}
+	// A migration never writes to the source, not even to create the
+	// user's bucket there; a user without one has no files to copy.
+	src, ok, err := storage.Existing(m.from, userID)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, errMissingSource
+	}
+	info, err := src.Stat(path)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, errMissingSource
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var dst storage.FileStorage
+	if m.dryRun {
+		// Resolving the destination may create it, e.g. the user's bucket.
+		existing, ok, err := storage.Existing(m.to, userID)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return true, nil
+		}
+		dst = existing
+	} else if dst, err = m.to.ForUser(userID); err != nil {
+		return false, err
+	}
+
+	same, data, err := sameContent(src, dst, path, info)
+	if err != nil || same {
+		return false, err
+	}
+	if m.dryRun {
+		return true, nil
+	}
+
+	if data == nil {
+		if data, err = src.Read(path); err != nil {
+			return false, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	sum := sha256.Sum256(data)
+	if err := dst.Save(path, data); err != nil {
+		return false, fmt.Errorf("failed to copy %s: %w", path, err)
+	}
+	written, err := dst.Read(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify %s: %w", path, err)
+	}
+	if sha256.Sum256(written) != sum {
+		return false, fmt.Errorf("checksum mismatch for %s after copying", path)
+	}
+	return true, nil
+}
+
+// sameContent reports whether dst already holds path with the content src
+// has, as described by info. Matching sizes and checksums settle it without
+// reading anything. Otherwise both copies are read and compared, since
+// decorators such as encryption store the same content as different bytes;
+// the source content is returned then so it needn't be read twice.
+func sameContent(src, dst storage.FileStorage, path string, info *storage.FileInfo) (bool, []byte, error) {
+	existing, err := dst.Stat(path)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to check %s at the destination: %w", path, err)
+	}
+	if existing.ETag != "" && existing.ETag == info.ETag && existing.Size == info.Size {
+		return true, nil, nil
+	}
+
+	data, err := src.Read(path)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	stored, err := dst.Read(path)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to check %s at the destination: %w", path, err)
+	}
+	return sha256.Sum256(stored) == sha256.Sum256(data), data, nil
+}
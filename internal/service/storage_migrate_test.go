@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"errors"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageMigrator_Run(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockMediaStore := new(MockMediaStore)
+	from := storage.NewMemoryStorage()
+	to := storage.NewMemoryStorage()
+
+	require.NoError(t, from.Save("user_1/post_1_v1.md", []byte("v1")))
+	require.NoError(t, from.Save("user_1/post_1_v2.md", []byte("v2")))
+	require.NoError(t, from.Save("user_2/media/a.png", []byte("png")))
+	require.NoError(t, from.Save("user_1/unreferenced.md", []byte("x")))
+	// Left behind by an earlier, interrupted run.
+	require.NoError(t, to.Save("user_1/post_1_v1.md", []byte("v1")))
+
+	mockPostStore.On("ListContentPaths").Return([]string{"user_1/post_1_v1.md", "user_1/post_1_v2.md", "user_1/post_9_v1.md"}, nil)
+	mockMediaStore.On("ListPaths").Return([]string{"user_2/media/a.png"}, nil)
+
+	// A dry run writes nothing.
+	report, err := service.NewStorageMigrator(mockPostStore, mockMediaStore, storage.NewSharedResolver(from), storage.NewSharedResolver(to), true).Run()
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Total)
+	assert.Equal(t, 2, report.Copied)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, []string{"user_1/post_9_v1.md"}, report.Missing)
+	exists, _ := to.Exists("user_1/post_1_v2.md")
+	assert.False(t, exists)
+
+	report, err = service.NewStorageMigrator(mockPostStore, mockMediaStore, storage.NewSharedResolver(from), storage.NewSharedResolver(to), false).Run()
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Copied)
+	assert.Equal(t, 1, report.Skipped)
+
+	for path, want := range map[string]string{"user_1/post_1_v2.md": "v2", "user_2/media/a.png": "png"} {
+		data, err := to.Read(path)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(data))
+	}
+	exists, _ = to.Exists("user_1/unreferenced.md")
+	assert.False(t, exists)
+
+	// Running again finds everything in place.
+	report, err = service.NewStorageMigrator(mockPostStore, mockMediaStore, storage.NewSharedResolver(from), storage.NewSharedResolver(to), false).Run()
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Copied)
+	assert.Equal(t, 3, report.Skipped)
+}
+
+func TestStorageMigrator_WriteFails(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockMediaStore := new(MockMediaStore)
+	from := storage.NewMemoryStorage()
+	to := storage.NewMemoryStorage()
+
+	require.NoError(t, from.Save("user_1/post_1_v1.md", []byte("v1")))
+	mockPostStore.On("ListContentPaths").Return([]string{"user_1/post_1_v1.md"}, nil)
+	mockMediaStore.On("ListPaths").Return([]string{}, nil)
+	to.FailNth(storage.OpSave, 1, errors.New("bucket unavailable"))
+
+	_, err := service.NewStorageMigrator(mockPostStore, mockMediaStore, storage.NewSharedResolver(from), storage.NewSharedResolver(to), false).Run()
+	assert.Error(t, err)
+}
+
+func TestStorageMigrator_SkipsByChecksum(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockMediaStore := new(MockMediaStore)
+	from := storage.NewMemoryStorage()
+	to := storage.NewMemoryStorage()
+
+	require.NoError(t, from.Save("user_1/post_1_v1.md", []byte("v1")))
+	require.NoError(t, to.Save("user_1/post_1_v1.md", []byte("v1")))
+	mockPostStore.On("ListContentPaths").Return([]string{"user_1/post_1_v1.md"}, nil)
+	mockMediaStore.On("ListPaths").Return([]string{}, nil)
+
+	report, err := service.NewStorageMigrator(mockPostStore, mockMediaStore, storage.NewSharedResolver(from), storage.NewSharedResolver(to), false).Run()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Zero(t, from.Calls(storage.OpRead))
+	assert.Zero(t, to.Calls(storage.OpRead))
+}
+
+// creatingResolver stands in for BucketResolver, whose ForUser creates
+// the user's bucket. The buckets of the users in existing are there
+// already.
+type creatingResolver struct {
+	storage.Resolver
+	existing map[int]bool
+	created  bool
+}
+
+func (r *creatingResolver) ForUser(userID int) (storage.FileStorage, error) {
+	r.created = true
+	return r.Resolver.ForUser(userID)
+}
+
+func (r *creatingResolver) Existing(userID int) (storage.FileStorage, bool, error) {
+	if !r.existing[userID] {
+		return nil, false, nil
+	}
+	fs, err := r.Resolver.ForUser(userID)
+	return fs, err == nil, err
+}
+
+func TestStorageMigrator_DryRunCreatesNothing(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockMediaStore := new(MockMediaStore)
+	from := storage.NewMemoryStorage()
+	to := &creatingResolver{Resolver: storage.NewSharedResolver(storage.NewMemoryStorage())}
+
+	require.NoError(t, from.Save("user_1/post_1_v1.md", []byte("v1")))
+	mockPostStore.On("ListContentPaths").Return([]string{"user_1/post_1_v1.md"}, nil)
+	mockMediaStore.On("ListPaths").Return([]string{}, nil)
+
+	report, err := service.NewStorageMigrator(mockPostStore, mockMediaStore, storage.NewSharedResolver(from), to, true).Run()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Copied)
+	assert.False(t, to.created)
+}
+
+func TestStorageMigrator_SourceIsReadOnly(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	mockMediaStore := new(MockMediaStore)
+	files := storage.NewMemoryStorage()
+	from := &creatingResolver{Resolver: storage.NewSharedResolver(files), existing: map[int]bool{1: true}}
+	to := storage.NewMemoryStorage()
+
+	require.NoError(t, files.Save("user_1/post_1_v1.md", []byte("v1")))
+	mockPostStore.On("ListContentPaths").Return([]string{"user_1/post_1_v1.md", "user_2/post_2_v1.md"}, nil)
+	mockMediaStore.On("ListPaths").Return([]string{}, nil)
+
+	// User 2 has no bucket on the source, so their file is missing rather
+	// than a reason to create one.
+	report, err := service.NewStorageMigrator(mockPostStore, mockMediaStore, from, storage.NewSharedResolver(to), false).Run()
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Copied)
+	assert.Equal(t, []string{"user_2/post_2_v1.md"}, report.Missing)
+	assert.False(t, from.created)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-blog/internal/storage"
+	"go-blog/internal/store"
+)
+
+// PurgeReport summarises a trash purge run.
+type PurgeReport struct {
+	Posts int `json:"posts"`
+	Files int `json:"files"`
+}
+
+// TrashPurger permanently removes posts that have been in the trash for
+// longer than the retention period, together with the content files of all
+// their versions.
+type TrashPurger struct {
+	postStore store.PostStore
+	storage   storage.Resolver
+	retention time.Duration
+}
+
+// NewTrashPurger creates a TrashPurger for posts deleted more than retention ago.
+func NewTrashPurger(ps store.PostStore, resolver storage.Resolver, retention time.Duration) *TrashPurger {
+	return &TrashPurger{postStore: ps, storage: resolver, retention: retention}
+}
+
+// Purge runs a single pass. Rows are removed before files, so a post is
+// never left without its content; files that can't be removed are only
+// logged, and the content GC picks them up later.
+func (p *TrashPurger) Purge() (*PurgeReport, error) {
+	posts, err := p.postStore.ListDeletedBefore(time.Now().Add(-p.retention))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired posts: %w", err)
+	}
+
+	report := &PurgeReport{}
+	for _, post := range posts {
+		history, err := p.postStore.ListHistory(post.ID)
+		if err != nil {
+			return report, fmt.Errorf("failed to load history of post %d: %w", post.ID, err)
+		}
+		paths := []string{post.ContentPath}
+		for _, h := range history {
+			paths = append(paths, h.ContentPath)
+		}
+
+		if err := p.postStore.Purge(post.ID); err != nil {
+			// Restored since it was listed.
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			return report, fmt.Errorf("failed to purge post %d: %w", post.ID, err)
+		}
+		report.Posts++
+
+		fs, err := p.storage.ForUser(post.UserID)
+		if err != nil {
+			return report, err
+		}
+		seen := make(map[string]bool, len(paths))
+		for _, path := range paths {
+			// Blobs may be shared with other posts; the content GC removes
+			// them once nothing references them.
+			if path == "" || seen[path] || blobPattern.MatchString(path) {
+				continue
+			}
+			seen[path] = true
+			if err := fs.Delete(path); err != nil {
+				log.Printf("trash purge: could not remove %s: %v", path, err)
+				continue
+			}
+			report.Files++
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,55 @@
+package service_test
+
+import (
+	"database/sql"
+	"go-blog/internal/model"
+	"go-blog/internal/service"
+	"go-blog/internal/storage"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestTrashPurger_Purge(t *testing.T) {
+	mockPostStore := new(MockPostStore)
+	fileStorage := storage.NewMemoryStorage()
+
+	blob := "user_1/blobs/" + strings.Repeat("a", 64) + ".md"
+	for _, path := range []string{"user_1/post_1_v1.md", "user_1/post_1_v2.md", blob, "user_1/post_2_v1.md"} {
+		fileStorage.Save(path, []byte("content"))
+	}
+
+	expired := []*model.Post{
+		{ID: 1, UserID: 1, ContentPath: blob},
+		{ID: 2, UserID: 1, ContentPath: "user_1/post_2_v1.md"}, // restored in the meantime
+	}
+	mockPostStore.On("ListDeletedBefore", mock.AnythingOfType("time.Time")).Return(expired, nil).Once()
+	mockPostStore.On("ListHistory", 1).Return([]*model.PostHistory{
+		{PostID: 1, Version: 2, ContentPath: "user_1/post_1_v2.md"},
+		{PostID: 1, Version: 1, ContentPath: "user_1/post_1_v1.md"},
+	}, nil).Once()
+	mockPostStore.On("ListHistory", 2).Return([]*model.PostHistory{}, nil).Once()
+	mockPostStore.On("Purge", 1).Return(nil).Once()
+	mockPostStore.On("Purge", 2).Return(sql.ErrNoRows).Once()
+
+	purger := service.NewTrashPurger(mockPostStore, storage.NewSharedResolver(fileStorage), 30*24*time.Hour)
+	report, err := purger.Purge()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Posts)
+	assert.Equal(t, 2, report.Files)
+
+	files, err := fileStorage.List("user_1/")
+	assert.NoError(t, err)
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	// The blob is left for the content GC, the restored post keeps its content.
+	assert.ElementsMatch(t, []string{blob, "user_1/post_2_v1.md"}, paths)
+
+	mockPostStore.AssertExpectations(t)
+}
@@ -10,6 +10,8 @@ import (
 type UserService interface {
 	Login(email, password string) (*model.User, error)
 	GetByID(id int) (*model.User, error)
+	// GetByUsername returns ErrNotFound for unknown usernames.
+	GetByUsername(username string) (*model.User, error)
 	Register(user *model.User) (*model.User, error)
 }
 
@@ -27,6 +29,15 @@ func (s *userService) GetByID(id int) (*model.User, error) {
 	return s.userStore.GetByID(id)
 }
 
+// GetByUsername retrieves a user by their username.
+func (s *userService) GetByUsername(username string) (*model.User, error) {
+	user, err := s.userStore.GetByUsername(username)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
 // Register creates a new user after hashing their password.
 func (s *userService) Register(user *model.User) (*model.User, error) {
 	// Hash the password before saving
@@ -152,6 +152,24 @@ func TestUserService_GetByID_Error(t *testing.T) {
 	mockStore.AssertExpectations(t)
 }
 
+func TestUserService_GetByUsername(t *testing.T) {
+	mockStore := new(MockUserStore)
+	userService := service.NewUserService(mockStore)
+
+	expectedUser := &model.User{ID: 1, Username: "testuser"}
+	mockStore.On("GetByUsername", "testuser").Return(expectedUser, nil).Once()
+	mockStore.On("GetByUsername", "nobody").Return(nil, errors.New("sql: no rows in result set")).Once()
+
+	result, err := userService.GetByUsername("testuser")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedUser, result)
+
+	_, err = userService.GetByUsername("nobody")
+	assert.ErrorIs(t, err, service.ErrNotFound)
+
+	mockStore.AssertExpectations(t)
+}
+
 func TestUserService_Login(t *testing.T) {
 	mockStore := new(MockUserStore)
 	userService := service.NewUserService(mockStore)
@@ -0,0 +1,67 @@
+// Package slug turns titles into URL path segments such as
+// "xin-chao-the-gioi" for "Xin chào thế giới".
+//
+// Letters are transliterated to ASCII by dropping their diacritics, which
+// covers Vietnamese and most other Latin-script languages. Characters
+// without an ASCII equivalent are treated as separators.
+package slug
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength is the longest slug Make returns.
+const MaxLength = 80
+
+// replacements covers letters that are not written as a base letter plus
+// combining marks, so decomposing them leaves nothing to strip.
+var replacements = map[rune]string{
+	'đ': "d", 'Đ': "d",
+	'ß': "ss",
+	'æ': "ae", 'Æ': "ae",
+	'œ': "oe", 'Œ': "oe",
+	'ø': "o", 'Ø': "o",
+	'ł': "l", 'Ł': "l",
+	'þ': "th", 'Þ': "th",
+}
+
+// Make returns the slug for s: lowercase ASCII letters and digits, with
+// runs of anything else collapsed into single hyphens. It returns "" if s
+// has nothing to transliterate.
+func Make(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if rep, ok := replacements[r]; ok {
+			b.WriteString(rep)
+			hyphen = false
+			continue
+		}
+		r = unicode.ToLower(r)
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > MaxLength {
+		// Cut at a word boundary where there is one.
+		slug = slug[:MaxLength]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	return slug
+}
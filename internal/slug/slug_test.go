@@ -0,0 +1,35 @@
+package slug_test
+
+import (
+	"go-blog/internal/slug"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	tests := map[string]string{
+		"Hello, World!":              "hello-world",
+		"  Go 1.24 -- what's new?  ": "go-1-24-what-s-new",
+		"Xin chào thế giới":          "xin-chao-the-gioi",
+		"Đường phố Hà Nội":           "duong-pho-ha-noi",
+		"Trường Đại học Bách khoa":   "truong-dai-hoc-bach-khoa",
+		"Ærøskøbing straße":          "aeroskobing-strasse",
+		"Crème brûlée":               "creme-brulee",
+		"日本語":                        "",
+		"Tokyo 東京 guide":             "tokyo-guide",
+		"ALREADY-a-slug":             "already-a-slug",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, slug.Make(in), in)
+	}
+}
+
+func TestMake_Truncates(t *testing.T) {
+	s := slug.Make(strings.Repeat("lorem ipsum ", 20))
+
+	assert.LessOrEqual(t, len(s), slug.MaxLength)
+	assert.False(t, strings.HasSuffix(s, "-"))
+	assert.True(t, strings.HasSuffix(s, "lorem") || strings.HasSuffix(s, "ipsum"))
+}
@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Cache keeps recently read files in memory, and optionally on local disk,
+// so repeated reads don't go back to the underlying storage. Content files
+// are written once per version and never change, so entries don't expire;
+// writes and deletes made through a cached storage evict the path.
+//
+// One Cache can back any number of storages, e.g. every bucket of a
+// BucketResolver, and its size limits apply to all of them together.
+type Cache struct {
+	mu     sync.Mutex
+	memory *lru
+	disk   *lru // nil without a disk tier
+	loads  map[string]*load
+
+	diskStore *LocalStorage
+}
+
+// load tracks the reads of a key that missed the cache and are fetching it
+// from the storage. A write evicting the key meanwhile bumps evictions, so
+// the data those reads got, which may predate the write, isn't cached.
+type load struct {
+	readers   int
+	evictions int
+}
+
+// NewCache creates a cache holding up to memoryBytes of file contents in
+// memory. When diskDir is set, files evicted from memory are still kept in
+// that directory, up to diskBytes.
+//
+// diskDir is emptied first. Other processes, such as blogctl, write to the
+// storage without going through this cache, so what an earlier run left
+// behind may be stale.
+func NewCache(memoryBytes int64, diskDir string, diskBytes int64) (*Cache, error) {
+	c := &Cache{memory: newLRU(memoryBytes), loads: make(map[string]*load)}
+	if diskDir == "" {
+		return c, nil
+	}
+
+	diskStore, err := NewLocalStorage(diskDir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := diskStore.List("")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if err := diskStore.Delete(f.Path); err != nil {
+			return nil, fmt.Errorf("could not clear %s: %w", diskDir, err)
+		}
+	}
+	c.diskStore = diskStore
+	c.disk = newLRU(diskBytes)
+	return c, nil
+}
+
+// Wrap returns fs with reads served through the cache. name keeps the
+// entries of different storages apart, e.g. a bucket name; it must be a
+// valid path segment, or empty for a cache used by a single storage.
+func (c *Cache) Wrap(name string, fs FileStorage) FileStorage {
+	if name == "" {
+		name = "default"
+	}
+	return &cachedStorage{cache: c, name: name, base: fs}
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	data, ok := c.memory.get(key)
+	inDisk := c.disk != nil && c.disk.has(key)
+	c.mu.Unlock()
+	if ok {
+		return data, true
+	}
+	if !inDisk {
+		return nil, false
+	}
+
+	// A concurrent eviction may remove the file; that is just a miss.
+	data, err := c.diskStore.Read(key)
+	if err != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	// Unless it was evicted while being read.
+	if _, ok := c.disk.get(key); ok {
+		c.memory.add(key, data, int64(len(data)))
+	}
+	c.mu.Unlock()
+	return data, true
+}
+
+// startLoad registers a read of key that missed the cache and returns the
+// generation to pass to put. endLoad must be called once the read is done.
+func (c *Cache) startLoad(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.loads[key]
+	if !ok {
+		l = &load{}
+		c.loads[key] = l
+	}
+	l.readers++
+	return l.evictions
+}
+
+func (c *Cache) endLoad(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l := c.loads[key]
+	l.readers--
+	if l.readers == 0 {
+		delete(c.loads, key)
+	}
+}
+
+// put caches data read for key since startLoad returned gen, unless key
+// was evicted in the meantime. The caller must not have called endLoad yet.
+func (c *Cache) put(key string, gen int, data []byte) {
+	c.mu.Lock()
+	fresh := c.loads[key].evictions == gen
+	if fresh {
+		c.memory.add(key, data, int64(len(data)))
+	}
+	c.mu.Unlock()
+
+	if !fresh || c.disk == nil || int64(len(data)) > c.disk.maxBytes {
+		return
+	}
+	if err := c.diskStore.Save(key, data); err != nil {
+		log.Printf("storage cache: could not write %s: %v", key, err)
+		return
+	}
+	c.mu.Lock()
+	var evicted []string
+	if c.loads[key].evictions == gen {
+		evicted = c.disk.add(key, nil, int64(len(data)))
+	} else {
+		// Evicted while being written; the file may be stale.
+		evicted = []string{key}
+	}
+	c.mu.Unlock()
+
+	// Deleted without the lock, so reads don't wait for the disk.
+	for _, key := range evicted {
+		if err := c.diskStore.Delete(key); err != nil {
+			log.Printf("storage cache: could not evict %s: %v", key, err)
+		}
+	}
+}
+
+func (c *Cache) evict(key string) {
+	c.mu.Lock()
+	c.memory.remove(key)
+	inDisk := c.disk != nil && c.disk.remove(key)
+	if l, ok := c.loads[key]; ok {
+		l.evictions++
+	}
+	c.mu.Unlock()
+
+	if inDisk {
+		if err := c.diskStore.Delete(key); err != nil {
+			log.Printf("storage cache: could not evict %s: %v", key, err)
+		}
+	}
+}
+
+// cachedStorage is a FileStorage whose reads go through a Cache.
+type cachedStorage struct {
+	cache *Cache
+	name  string
+	base  FileStorage
+}
+
+func (s *cachedStorage) key(path string) string {
+	return s.name + "/" + path
+}
+
+func (s *cachedStorage) Read(path string) ([]byte, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	key := s.key(path)
+	if data, ok := s.cache.get(key); ok {
+		return bytes.Clone(data), nil
+	}
+
+	gen := s.cache.startLoad(key)
+	defer s.cache.endLoad(key)
+	data, err := s.base.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(key, gen, bytes.Clone(data))
+	return data, nil
+}
+
+// ReadStream serves cached files from the cache but doesn't populate it:
+// streams are used for large files that would crowd out everything else.
+func (s *cachedStorage) ReadStream(path string) (io.ReadCloser, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	if data, ok := s.cache.get(s.key(path)); ok {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return s.base.ReadStream(path)
+}
+
+func (s *cachedStorage) Save(path string, data []byte) error {
+	defer s.cache.evict(s.key(path))
+	return s.base.Save(path, data)
+}
+
+func (s *cachedStorage) SaveStream(path string, r io.Reader) error {
+	defer s.cache.evict(s.key(path))
+	return s.base.SaveStream(path, r)
+}
+
+func (s *cachedStorage) Delete(path string) error {
+	defer s.cache.evict(s.key(path))
+	return s.base.Delete(path)
+}
+
+func (s *cachedStorage) Exists(path string) (bool, error) {
+	return s.base.Exists(path)
+}
+
+func (s *cachedStorage) Stat(path string) (*FileInfo, error) {
+	return s.base.Stat(path)
+}
+
+func (s *cachedStorage) List(prefix string) ([]FileInfo, error) {
+	return s.base.List(prefix)
+}
+
+func (s *cachedStorage) URL(path string, ttl time.Duration) (string, error) {
+	return URL(s.base, path, ttl)
+}
+
+// lru tracks entries by total size and evicts the least recently used ones
+// once maxBytes is exceeded. It is not safe for concurrent use.
+type lru struct {
+	maxBytes int64
+	size     int64
+	order    *list.List // front is most recently used
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key  string
+	data []byte
+	size int64
+}
+
+func newLRU(maxBytes int64) *lru {
+	return &lru{maxBytes: maxBytes, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *lru) has(key string) bool {
+	_, ok := l.items[key]
+	return ok
+}
+
+func (l *lru) get(key string) ([]byte, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*lruEntry).data, true
+}
+
+// add stores an entry unless it alone is larger than the whole cache, and
+// returns the keys evicted to make room for it.
+func (l *lru) add(key string, data []byte, size int64) []string {
+	if size > l.maxBytes {
+		return nil
+	}
+	l.remove(key)
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, data: data, size: size})
+	l.size += size
+
+	var evicted []string
+	for l.size > l.maxBytes {
+		oldest := l.order.Back().Value.(*lruEntry)
+		l.remove(oldest.key)
+		evicted = append(evicted, oldest.key)
+	}
+	return evicted
+}
+
+// remove drops an entry and reports whether it was there.
+func (l *lru) remove(key string) bool {
+	el, ok := l.items[key]
+	if !ok {
+		return false
+	}
+	l.order.Remove(el)
+	delete(l.items, key)
+	l.size -= el.Value.(*lruEntry).size
+	return true
+}
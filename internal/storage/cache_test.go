@@ -0,0 +1,161 @@
+package storage_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"go-blog/internal/storage"
+	"go-blog/internal/storage/storagetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileStorage {
+		cache, err := storage.NewCache(1<<20, "", 0)
+		require.NoError(t, err)
+		return cache.Wrap("", storage.NewMemoryStorage())
+	})
+}
+
+func TestCachedStorage_DiskTier(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileStorage {
+		cache, err := storage.NewCache(0, t.TempDir(), 1<<20)
+		require.NoError(t, err)
+		return cache.Wrap("", storage.NewMemoryStorage())
+	})
+}
+
+func TestCache_ServesRepeatedReads(t *testing.T) {
+	base := storage.NewMemoryStorage()
+	require.NoError(t, base.Save("user_1/post_1_v1.md", []byte("hello")))
+
+	cache, err := storage.NewCache(1<<20, "", 0)
+	require.NoError(t, err)
+	fs := cache.Wrap("", base)
+
+	for i := 0; i < 3; i++ {
+		data, err := fs.Read("user_1/post_1_v1.md")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hello"), data)
+	}
+	assert.Equal(t, 1, base.Calls(storage.OpRead))
+
+	// Streams are served from the cache too.
+	r, err := fs.ReadStream("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	assert.Equal(t, []byte("hello"), data)
+	assert.Equal(t, 1, base.Calls(storage.OpRead))
+
+	// Callers can't corrupt the cached copy.
+	data, _ = fs.Read("user_1/post_1_v1.md")
+	data[0] = 'j'
+	data, _ = fs.Read("user_1/post_1_v1.md")
+	assert.Equal(t, []byte("hello"), data)
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	base := storage.NewMemoryStorage()
+	for _, p := range []string{"a.md", "b.md", "c.md"} {
+		require.NoError(t, base.Save(p, bytes.Repeat([]byte("x"), 40)))
+	}
+
+	cache, err := storage.NewCache(100, "", 0) // room for two files
+	require.NoError(t, err)
+	fs := cache.Wrap("", base)
+
+	fs.Read("a.md")
+	fs.Read("b.md")
+	fs.Read("a.md") // a is now more recent than b
+	fs.Read("c.md") // evicts b
+	assert.Equal(t, 3, base.Calls(storage.OpRead))
+
+	fs.Read("a.md")
+	fs.Read("c.md")
+	assert.Equal(t, 3, base.Calls(storage.OpRead))
+	fs.Read("b.md")
+	assert.Equal(t, 4, base.Calls(storage.OpRead))
+}
+
+func TestCache_WritesEvict(t *testing.T) {
+	base := storage.NewMemoryStorage()
+	cache, err := storage.NewCache(1<<20, "", 0)
+	require.NoError(t, err)
+	fs := cache.Wrap("", base)
+
+	require.NoError(t, fs.Save("a.md", []byte("one")))
+	fs.Read("a.md")
+	require.NoError(t, fs.Save("a.md", []byte("two")))
+	data, err := fs.Read("a.md")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("two"), data)
+
+	require.NoError(t, fs.Delete("a.md"))
+	_, err = fs.Read("a.md")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+// slowReads holds every Read after it has fetched the data until release
+// is closed.
+type slowReads struct {
+	storage.FileStorage
+	fetched chan struct{}
+	release chan struct{}
+}
+
+func (s *slowReads) Read(path string) ([]byte, error) {
+	data, err := s.FileStorage.Read(path)
+	s.fetched <- struct{}{}
+	<-s.release
+	return data, err
+}
+
+func TestCache_WriteDuringReadIsNotUndone(t *testing.T) {
+	for _, dir := range []string{"", t.TempDir()} {
+		base := storage.NewMemoryStorage()
+		require.NoError(t, base.Save("a.md", []byte("one")))
+		slow := &slowReads{FileStorage: base, fetched: make(chan struct{}, 1), release: make(chan struct{})}
+		cache, err := storage.NewCache(1<<20, dir, 1<<20)
+		require.NoError(t, err)
+		fs := cache.Wrap("", slow)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			fs.Read("a.md")
+		}()
+		// The read has the old content when the write lands, so it must
+		// not be cached afterwards.
+		<-slow.fetched
+		require.NoError(t, fs.Save("a.md", []byte("two")))
+		close(slow.release)
+		<-done
+
+		data, err := fs.Read("a.md")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("two"), data, "disk dir %q", dir)
+	}
+}
+
+func TestCache_DiskTierClearedOnStart(t *testing.T) {
+	dir := t.TempDir()
+	base := storage.NewMemoryStorage()
+	require.NoError(t, base.Save("user_1/post_1_v1.md", []byte("hello")))
+
+	cache, err := storage.NewCache(1<<20, dir, 1<<20)
+	require.NoError(t, err)
+	_, err = cache.Wrap("bucket-1", base).Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+
+	// Another process changes the file behind the cache's back, so a new
+	// one must not trust what the last one left on disk.
+	require.NoError(t, base.Save("user_1/post_1_v1.md", []byte("changed")))
+	cache, err = storage.NewCache(1<<20, dir, 1<<20)
+	require.NoError(t, err)
+	data, err := cache.Wrap("bucket-1", base).Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("changed"), data)
+}
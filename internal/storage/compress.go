@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// compressedMagic starts every file written compressed, followed by one
+// byte naming the encoding. Files without it are read as they are.
+var compressedMagic = []byte("\x00GBZ")
+
+// Compression encodings, as stored after compressedMagic.
+const (
+	encodingGzip byte = 1
+)
+
+// Compressor compresses text files, such as post markdown, before they are
+// stored. Other files, e.g. images, are already compressed and are passed
+// through untouched.
+type Compressor struct {
+	encoding byte
+}
+
+// NewCompressor creates a Compressor for the named encoding. Only "gzip" is
+// supported.
+func NewCompressor(encoding string) (*Compressor, error) {
+	switch encoding {
+	case "gzip":
+		return &Compressor{encoding: encodingGzip}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", encoding)
+	}
+}
+
+// Wrap returns fs with text files compressed at rest. It has the signature
+// of a Decorator.
+func (c *Compressor) Wrap(_ string, fs FileStorage) FileStorage {
+	return &compressedStorage{compressor: c, base: fs}
+}
+
+// compressible reports whether files at path are worth compressing.
+func compressible(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || strings.HasPrefix(mime.TypeByExtension(ext), "text/")
+}
+
+// writer starts a compressed file on w, writing the header first.
+func (c *Compressor) writer(w io.Writer) (io.WriteCloser, error) {
+	if _, err := w.Write(append(append([]byte{}, compressedMagic...), c.encoding)); err != nil {
+		return nil, err
+	}
+	return gzip.NewWriter(w), nil
+}
+
+// reader returns a reader over the decompressed content of a file read from
+// r, or over the file as it is if it was not written compressed.
+func reader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(len(compressedMagic) + 1)
+	if err != nil || !bytes.Equal(header[:len(compressedMagic)], compressedMagic) {
+		// Short files can't be compressed ones; Peek's error is io.EOF then.
+		return br, nil
+	}
+	br.Discard(len(header))
+
+	switch header[len(compressedMagic)] {
+	case encodingGzip:
+		return gzip.NewReader(br)
+	default:
+		return nil, fmt.Errorf("unknown compression encoding %d", header[len(compressedMagic)])
+	}
+}
+
+// compressedStorage is a FileStorage that compresses text files before they
+// reach the underlying storage.
+//
+// Stat and List describe the stored bytes, so sizes and ETags are those of
+// the compressed files. Compressed files have no URLs, since the stored
+// bytes are not what a browser expects.
+type compressedStorage struct {
+	compressor *Compressor
+	base       FileStorage
+}
+
+// Save stores data compressed, unless compressing doesn't make it smaller.
+func (s *compressedStorage) Save(path string, data []byte) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	if !compressible(path) {
+		return s.base.Save(path, data)
+	}
+
+	var buf bytes.Buffer
+	w, err := s.compressor.writer(&buf)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if buf.Len() >= len(data) {
+		return s.base.Save(path, data)
+	}
+	return s.base.Save(path, buf.Bytes())
+}
+
+func (s *compressedStorage) Read(path string) ([]byte, error) {
+	data, err := s.base.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, compressedMagic) {
+		return data, nil
+	}
+	r, err := reader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	data, err = io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// SaveStream compresses text files on the fly. The size isn't known up
+// front, so unlike Save it always stores them compressed.
+func (s *compressedStorage) SaveStream(path string, r io.Reader) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	if !compressible(path) {
+		return s.base.SaveStream(path, r)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := s.compressor.writer(pw)
+		if err == nil {
+			_, err = io.Copy(w, r)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err := s.base.SaveStream(path, pr)
+	// Unblocks the goroutine if the storage stopped reading early.
+	pr.CloseWithError(err)
+	return err
+}
+
+func (s *compressedStorage) ReadStream(path string) (io.ReadCloser, error) {
+	rc, err := s.base.ReadStream(path)
+	if err != nil || !compressible(path) {
+		return rc, err
+	}
+	r, err := reader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, rc}, nil
+}
+
+func (s *compressedStorage) Delete(path string) error {
+	return s.base.Delete(path)
+}
+
+func (s *compressedStorage) Exists(path string) (bool, error) {
+	return s.base.Exists(path)
+}
+
+func (s *compressedStorage) Stat(path string) (*FileInfo, error) {
+	return s.base.Stat(path)
+}
+
+func (s *compressedStorage) List(prefix string) ([]FileInfo, error) {
+	return s.base.List(prefix)
+}
+
+func (s *compressedStorage) URL(path string, ttl time.Duration) (string, error) {
+	if compressible(path) {
+		return "", ErrURLNotSupported
+	}
+	return URL(s.base, path, ttl)
+}
@@ -0,0 +1,111 @@
+package storage_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go-blog/internal/storage"
+	"go-blog/internal/storage/storagetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileStorage {
+		c, err := storage.NewCompressor("gzip")
+		require.NoError(t, err)
+		return c.Wrap("", storage.NewMemoryStorage())
+	})
+}
+
+func TestCompressedStorage_CompressesMarkdown(t *testing.T) {
+	c, err := storage.NewCompressor("gzip")
+	require.NoError(t, err)
+	base := storage.NewMemoryStorage()
+	fs := c.Wrap("", base)
+
+	content := strings.Repeat("# A heading\n\nSome repetitive markdown.\n", 100)
+	require.NoError(t, fs.Save("user_1/post_1_v1.md", []byte(content)))
+	require.NoError(t, fs.SaveStream("user_1/post_1_v2.md", strings.NewReader(content)))
+
+	for _, path := range []string{"user_1/post_1_v1.md", "user_1/post_1_v2.md"} {
+		info, err := base.Stat(path)
+		require.NoError(t, err)
+		assert.Less(t, info.Size, int64(len(content)/4), path)
+
+		data, err := fs.Read(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+
+		r, err := fs.ReadStream(path)
+		require.NoError(t, err)
+		data, err = io.ReadAll(r)
+		r.Close()
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data))
+	}
+}
+
+func TestCompressedStorage_ReadsUncompressed(t *testing.T) {
+	c, err := storage.NewCompressor("gzip")
+	require.NoError(t, err)
+	base := storage.NewMemoryStorage()
+	content := strings.Repeat("written before compression\n", 100)
+	require.NoError(t, base.Save("user_1/post_1_v1.md", []byte(content)))
+
+	fs := c.Wrap("", base)
+	data, err := fs.Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestCompressedStorage_SkipsImages(t *testing.T) {
+	c, err := storage.NewCompressor("gzip")
+	require.NoError(t, err)
+	local, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	local.SignURLs(storage.NewHMACSigner("/files/", []byte("secret")))
+	fs := c.Wrap("", local)
+
+	image := bytes.Repeat([]byte{0}, 4096)
+	require.NoError(t, fs.Save("user_1/media/a.png", image))
+	stored, err := local.Read("user_1/media/a.png")
+	require.NoError(t, err)
+	assert.Equal(t, image, stored)
+
+	// Images keep their direct download URLs; compressed markdown can't.
+	_, err = storage.URL(fs, "user_1/media/a.png", time.Minute)
+	assert.NoError(t, err)
+	_, err = storage.URL(fs, "user_1/post_1_v1.md", time.Minute)
+	assert.ErrorIs(t, err, storage.ErrURLNotSupported)
+}
+
+func TestCompressedStorage_WithEncryption(t *testing.T) {
+	c, err := storage.NewCompressor("gzip")
+	require.NoError(t, err)
+	keys, err := storage.ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+	base := storage.NewMemoryStorage()
+	fs := c.Wrap("", keys.Wrap("", base))
+
+	content := strings.Repeat("secret and repetitive\n", 200)
+	require.NoError(t, fs.Save("user_1/post_1_v1.md", []byte(content)))
+
+	// Compressed before it was encrypted, or it wouldn't have shrunk.
+	info, err := base.Stat("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	assert.Less(t, info.Size, int64(len(content)/4))
+
+	data, err := fs.Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+}
+
+func TestNewCompressor_Unsupported(t *testing.T) {
+	_, err := storage.NewCompressor("brotli")
+	assert.Error(t, err)
+}
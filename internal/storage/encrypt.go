@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrDecrypt is returned when an encrypted file can't be decrypted, e.g.
+// because it was written with a key that is no longer configured.
+var ErrDecrypt = errors.New("could not decrypt file")
+
+// encryptedMagic starts every file written by an encrypted storage. Markdown
+// and the image formats media accepts never start with a NUL byte, so files
+// written before encryption was enabled are told apart by its absence.
+var encryptedMagic = []byte("\x00GBE")
+
+const encryptedVersion = 1
+
+// Keyring holds the master keys used for envelope encryption, by ID. New
+// files are encrypted with the primary key; the others are kept so files
+// written before a key rotation can still be read.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring creates a Keyring from 32-byte AES-256 master keys.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("invalid encryption key ID %q", id)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, got %d", id, len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+	}
+	if _, ok := k.keys[primary]; !ok {
+		return nil, fmt.Errorf("primary encryption key %q is not configured", primary)
+	}
+	return k, nil
+}
+
+// ParseKeyring parses keys in the form "id:base64key,id:base64key". The
+// first key is the primary one.
+func ParseKeyring(spec string) (*Keyring, error) {
+	var primary string
+	keys := make(map[string][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok {
+			return nil, fmt.Errorf("encryption key %q must be in the form id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q is not valid base64: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return nil, fmt.Errorf("encryption key %q is listed twice", id)
+		}
+		keys[id] = key
+		if primary == "" {
+			primary = id
+		}
+	}
+	return NewKeyring(primary, keys)
+}
+
+// Wrap returns fs with file contents encrypted at rest. It has the
+// signature of a Decorator.
+func (k *Keyring) Wrap(_ string, fs FileStorage) FileStorage {
+	return &encryptedStorage{keys: k, base: fs}
+}
+
+// seal encrypts data with a fresh data key, which is itself encrypted with
+// the primary master key. The result is laid out as
+//
+//	magic | version | key ID length | key ID | wrapped data key | nonce | ciphertext
+//
+// and the header up to the nonce is authenticated along with the content.
+func (k *Keyring) seal(data []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	master := k.keys[k.primary]
+	header := append([]byte{}, encryptedMagic...)
+	header = append(header, encryptedVersion, byte(len(k.primary)))
+	header = append(header, k.primary...)
+	header, err := appendSealed(header, master, dataKey, []byte(k.primary))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return appendSealed(header, aead, data, header)
+}
+
+// open decrypts data written by seal. Anything else is returned unchanged.
+func (k *Keyring) open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return data, nil
+	}
+
+	rest := data[len(encryptedMagic):]
+	if len(rest) < 2 || rest[0] != encryptedVersion || len(rest) < 2+int(rest[1]) {
+		return nil, fmt.Errorf("%w: malformed header", ErrDecrypt)
+	}
+	id := string(rest[2 : 2+int(rest[1])])
+	master, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrDecrypt, id)
+	}
+
+	rest = rest[2+len(id):]
+	wrappedLen := master.NonceSize() + 32 + master.Overhead()
+	if len(rest) < wrappedLen {
+		return nil, fmt.Errorf("%w: malformed header", ErrDecrypt)
+	}
+	dataKey, err := openSealed(master, rest[:wrappedLen], []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	header := data[:len(data)-len(rest)+wrappedLen]
+	return openSealed(aead, rest[wrappedLen:], header)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// appendSealed appends a random nonce and the sealed plaintext to dst.
+func appendSealed(dst []byte, aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plaintext, additional), nil
+}
+
+func openSealed(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated", ErrDecrypt)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
+
+// encryptedStorage is a FileStorage that encrypts contents before they reach
+// the underlying storage. Files that are not encrypted, e.g. ones written
+// before encryption was enabled, are read as they are.
+//
+// GCM needs the whole file at once, so streams are buffered in memory.
+// Stat and List describe the stored bytes, so sizes and ETags are those of
+// the encrypted files. It has no URLs: the stored bytes are useless to a
+// browser, so callers fall back to streaming decrypted content.
+type encryptedStorage struct {
+	keys *Keyring
+	base FileStorage
+}
+
+func (s *encryptedStorage) Save(path string, data []byte) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	sealed, err := s.keys.seal(data)
+	if err != nil {
+		return err
+	}
+	return s.base.Save(path, sealed)
+}
+
+func (s *encryptedStorage) Read(path string) ([]byte, error) {
+	data, err := s.base.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := s.keys.open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
+func (s *encryptedStorage) SaveStream(path string, r io.Reader) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return s.Save(path, data)
+}
+
+func (s *encryptedStorage) ReadStream(path string) (io.ReadCloser, error) {
+	data, err := s.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *encryptedStorage) Delete(path string) error {
+	return s.base.Delete(path)
+}
+
+func (s *encryptedStorage) Exists(path string) (bool, error) {
+	return s.base.Exists(path)
+}
+
+func (s *encryptedStorage) Stat(path string) (*FileInfo, error) {
+	return s.base.Stat(path)
+}
+
+func (s *encryptedStorage) List(prefix string) ([]FileInfo, error) {
+	return s.base.List(prefix)
+}
@@ -0,0 +1,119 @@
+package storage_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"testing"
+	"time"
+
+	"go-blog/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	keys, err := storage.ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+	base := storage.NewMemoryStorage()
+	fs := keys.Wrap("", base)
+
+	require.NoError(t, fs.Save("user_1/post_1_v1.md", []byte("# Secret draft")))
+	require.NoError(t, fs.SaveStream("user_1/media/a.png", bytes.NewReader([]byte("image bytes"))))
+
+	data, err := fs.Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	assert.Equal(t, "# Secret draft", string(data))
+
+	r, err := fs.ReadStream("user_1/media/a.png")
+	require.NoError(t, err)
+	data, err = io.ReadAll(r)
+	r.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "image bytes", string(data))
+
+	// Only ciphertext reaches the underlying storage.
+	stored, err := base.Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	assert.NotContains(t, string(stored), "Secret")
+
+	_, err = fs.Read("user_1/missing.md")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	assert.ErrorIs(t, fs.Save("../outside.md", []byte("x")), storage.ErrInvalidPath)
+}
+
+func TestEncryptedStorage_ReadsPlaintext(t *testing.T) {
+	keys, err := storage.ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+	base := storage.NewMemoryStorage()
+	require.NoError(t, base.Save("user_1/post_1_v1.md", []byte("written before encryption")))
+
+	data, err := keys.Wrap("", base).Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	assert.Equal(t, "written before encryption", string(data))
+}
+
+func TestEncryptedStorage_KeyRotation(t *testing.T) {
+	base := storage.NewMemoryStorage()
+	oldKeys, err := storage.ParseKeyring("old:" + testKey(1))
+	require.NoError(t, err)
+	require.NoError(t, oldKeys.Wrap("", base).Save("user_1/post_1_v1.md", []byte("v1")))
+
+	// The old key is still listed, so files written with it stay readable.
+	keys, err := storage.ParseKeyring("new:" + testKey(2) + ",old:" + testKey(1))
+	require.NoError(t, err)
+	data, err := keys.Wrap("", base).Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(data))
+
+	// Once it is dropped they are not.
+	newOnly, err := storage.ParseKeyring("new:" + testKey(2))
+	require.NoError(t, err)
+	_, err = newOnly.Wrap("", base).Read("user_1/post_1_v1.md")
+	assert.ErrorIs(t, err, storage.ErrDecrypt)
+}
+
+func TestEncryptedStorage_Tampered(t *testing.T) {
+	keys, err := storage.ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+	base := storage.NewMemoryStorage()
+	fs := keys.Wrap("", base)
+	require.NoError(t, fs.Save("user_1/post_1_v1.md", []byte("content")))
+
+	stored, err := base.Read("user_1/post_1_v1.md")
+	require.NoError(t, err)
+	stored[len(stored)-1] ^= 1
+	require.NoError(t, base.Save("user_1/post_1_v1.md", stored))
+
+	_, err = fs.Read("user_1/post_1_v1.md")
+	assert.ErrorIs(t, err, storage.ErrDecrypt)
+}
+
+func TestEncryptedStorage_NoURLs(t *testing.T) {
+	keys, err := storage.ParseKeyring("k1:" + testKey(1))
+	require.NoError(t, err)
+	local, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	local.SignURLs(storage.NewHMACSigner("/files/", []byte("secret")))
+
+	_, err = storage.URL(keys.Wrap("", local), "user_1/post_1_v1.md", time.Minute)
+	assert.ErrorIs(t, err, storage.ErrURLNotSupported)
+}
+
+func TestParseKeyring_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"k1",
+		"k1:not base64!",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("too short")),
+		"k1:" + testKey(1) + ",k1:" + testKey(2),
+	} {
+		_, err := storage.ParseKeyring(spec)
+		assert.Error(t, err, spec)
+	}
+}
@@ -1,24 +1,92 @@
 package storage
 
 import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
 type LocalStorage struct {
 	basePath string
+	signer   *HMACSigner
 }
 
 func NewLocalStorage(basePath string) (*LocalStorage, error) {
 	if err := os.MkdirAll(basePath, 0755); err != nil {
 		return nil, err
 	}
+	// Keep the fully resolved base so containment checks compare like with like.
+	basePath, err := filepath.Abs(basePath)
+	if err != nil {
+		return nil, err
+	}
+	basePath, err = filepath.EvalSymlinks(basePath)
+	if err != nil {
+		return nil, err
+	}
 	return &LocalStorage{basePath: basePath}, nil
 }
 
+// SignURLs enables URL, which then returns links signed by signer. The
+// application must serve them; see LocalURLPrefix.
+func (s *LocalStorage) SignURLs(signer *HMACSigner) {
+	s.signer = signer
+}
+
+// URL returns a signed link to path, or ErrURLNotSupported unless SignURLs
+// has been called.
+func (s *LocalStorage) URL(path string, ttl time.Duration) (string, error) {
+	if s.signer == nil {
+		return "", ErrURLNotSupported
+	}
+	if err := ValidatePath(path); err != nil {
+		return "", err
+	}
+	return s.signer.Sign(path, ttl), nil
+}
+
+// resolve validates path and maps it to a location on disk. Besides the
+// lexical checks in ValidatePath it makes sure no symlink along the way
+// points outside the base directory.
+func (s *LocalStorage) resolve(path string) (string, error) {
+	if err := ValidatePath(path); err != nil {
+		return "", err
+	}
+	fullPath := filepath.Join(s.basePath, filepath.FromSlash(path))
+
+	// Resolve the longest part of the path that already exists; the rest
+	// will be created as plain directories and files inside it.
+	for existing := fullPath; existing != s.basePath; existing = filepath.Dir(existing) {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if resolved != s.basePath && !strings.HasPrefix(resolved, s.basePath+string(filepath.Separator)) {
+				return "", invalidPath(path)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		// A dangling symlink would be followed when the file is created.
+		if info, err := os.Lstat(existing); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return "", invalidPath(path)
+		}
+	}
+	return fullPath, nil
+}
+
 func (s *LocalStorage) Save(path string, data []byte) error {
-	fullPath := filepath.Join(s.basePath, path)
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
 		return err
 	}
@@ -26,6 +94,150 @@ func (s *LocalStorage) Save(path string, data []byte) error {
 }
 
 func (s *LocalStorage) Read(path string) ([]byte, error) {
-	fullPath := filepath.Join(s.basePath, path)
-	return ioutil.ReadFile(fullPath)
-}
\ No newline at end of file
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(fullPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	return data, err
+}
+
+func (s *LocalStorage) SaveStream(path string, r io.Reader) error {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial file.
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fullPath)
+}
+
+func (s *LocalStorage) ReadStream(path string) (io.ReadCloser, error) {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fullPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	return f, err
+}
+
+func (s *LocalStorage) Delete(path string) error {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Exists(path string) (bool, error) {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+func (s *LocalStorage) Stat(path string) (*FileInfo, error) {
+	fullPath, err := s.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fullPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, err
+	}
+
+	return &FileInfo{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		ETag:    hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+func (s *LocalStorage) List(prefix string) ([]FileInfo, error) {
+	if err := validatePrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	var files []FileInfo
+	err := filepath.WalkDir(s.basePath, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.basePath, fullPath)
+		if err != nil {
+			return err
+		}
+		// Paths are always reported with forward slashes, like S3 keys.
+		path := filepath.ToSlash(rel)
+		if !strings.HasPrefix(path, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, FileInfo{Path: path, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,56 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-blog/internal/storage"
+	"go-blog/internal/storage/storagetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileStorage {
+		s, err := storage.NewLocalStorage(t.TempDir())
+		require.NoError(t, err)
+		return s
+	})
+}
+
+func TestLocalStorage_SymlinkEscape(t *testing.T) {
+	base := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.md"), []byte("secret"), 0644))
+
+	// A directory link and a file link pointing out of the base, plus a
+	// dangling link that would create a file outside when written through.
+	require.NoError(t, os.Symlink(outside, filepath.Join(base, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.md"), filepath.Join(base, "secret.md")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "new.md"), filepath.Join(base, "dangling.md")))
+
+	s, err := storage.NewLocalStorage(base)
+	require.NoError(t, err)
+
+	_, err = s.Read("escape/secret.md")
+	assert.ErrorIs(t, err, storage.ErrInvalidPath)
+	_, err = s.Read("secret.md")
+	assert.ErrorIs(t, err, storage.ErrInvalidPath)
+	assert.ErrorIs(t, s.Save("escape/new.md", []byte("x")), storage.ErrInvalidPath)
+	assert.ErrorIs(t, s.Save("dangling.md", []byte("x")), storage.ErrInvalidPath)
+	assert.ErrorIs(t, s.Delete("escape/secret.md"), storage.ErrInvalidPath)
+
+	_, err = os.Stat(filepath.Join(outside, "new.md"))
+	assert.True(t, os.IsNotExist(err), "nothing may be written outside the base")
+	_, err = os.Stat(filepath.Join(outside, "secret.md"))
+	assert.NoError(t, err)
+
+	// Links that stay inside the base keep working.
+	require.NoError(t, s.Save("real/file.md", []byte("inside")))
+	require.NoError(t, os.Symlink(filepath.Join(base, "real"), filepath.Join(base, "alias")))
+	data, err := s.Read("alias/file.md")
+	assert.NoError(t, err)
+	assert.Equal(t, "inside", string(data))
+}
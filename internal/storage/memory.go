@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op names a kind of FileStorage call for fault injection.
+type Op string
+
+const (
+	OpSave   Op = "save"   // Save and SaveStream
+	OpRead   Op = "read"   // Read and ReadStream
+	OpDelete Op = "delete" // Delete
+	OpStat   Op = "stat"   // Exists and Stat
+	OpList   Op = "list"   // List
+)
+
+type memoryFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+// MemoryStorage is a FileStorage that keeps files in a map. It is safe for
+// concurrent use and can be told to fail specific calls, which makes it a
+// real backend for tests and local demos that shouldn't touch ./files.
+type MemoryStorage struct {
+	mu     sync.Mutex
+	files  map[string]memoryFile
+	calls  map[Op]int
+	faults map[Op]map[int]error
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files:  make(map[string]memoryFile),
+		calls:  make(map[Op]int),
+		faults: make(map[Op]map[int]error),
+	}
+}
+
+// FailNth makes the nth call to op from now on (1-based) return err.
+// For example FailNth(OpSave, 2, err) lets the next Save succeed and fails
+// the one after it. Each injected fault fires once.
+func (s *MemoryStorage) FailNth(op Op, n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.faults[op] == nil {
+		s.faults[op] = make(map[int]error)
+	}
+	s.faults[op][s.calls[op]+n] = err
+}
+
+// Calls returns how many times op has been called.
+func (s *MemoryStorage) Calls(op Op) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[op]
+}
+
+// call records a call to op and returns the injected fault, if any.
+// The caller must hold s.mu.
+func (s *MemoryStorage) call(op Op) error {
+	s.calls[op]++
+	err, ok := s.faults[op][s.calls[op]]
+	if ok {
+		delete(s.faults[op], s.calls[op])
+	}
+	return err
+}
+
+func (s *MemoryStorage) Save(path string, data []byte) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(OpSave); err != nil {
+		return err
+	}
+	s.files[path] = memoryFile{data: append([]byte(nil), data...), modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) SaveStream(path string, r io.Reader) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	// Read outside the lock so a slow reader doesn't block other callers.
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(OpSave); err != nil {
+		return err
+	}
+	s.files[path] = memoryFile{data: data, modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) Read(path string) ([]byte, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(OpRead); err != nil {
+		return nil, err
+	}
+	f, ok := s.files[path]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (s *MemoryStorage) ReadStream(path string) (io.ReadCloser, error) {
+	data, err := s.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *MemoryStorage) Delete(path string) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(OpDelete); err != nil {
+		return err
+	}
+	delete(s.files, path)
+	return nil
+}
+
+func (s *MemoryStorage) Exists(path string) (bool, error) {
+	if err := ValidatePath(path); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(OpStat); err != nil {
+		return false, err
+	}
+	_, ok := s.files[path]
+	return ok, nil
+}
+
+func (s *MemoryStorage) Stat(path string) (*FileInfo, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(OpStat); err != nil {
+		return nil, err
+	}
+	f, ok := s.files[path]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	sum := md5.Sum(f.data)
+	return &FileInfo{
+		Path:    path,
+		Size:    int64(len(f.data)),
+		ModTime: f.modTime,
+		ETag:    hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+func (s *MemoryStorage) List(prefix string) ([]FileInfo, error) {
+	if err := validatePrefix(prefix); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(OpList); err != nil {
+		return nil, err
+	}
+	var files []FileInfo
+	for path, f := range s.files {
+		if strings.HasPrefix(path, prefix) {
+			files = append(files, FileInfo{Path: path, Size: int64(len(f.data)), ModTime: f.modTime})
+		}
+	}
+	return files, nil
+}
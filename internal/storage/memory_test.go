@@ -0,0 +1,35 @@
+package storage_test
+
+import (
+	"errors"
+	"testing"
+
+	"go-blog/internal/storage"
+	"go-blog/internal/storage/storagetest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.FileStorage {
+		return storage.NewMemoryStorage()
+	})
+}
+
+func TestMemoryStorage_FailNth(t *testing.T) {
+	s := storage.NewMemoryStorage()
+	injected := errors.New("disk full")
+
+	assert.NoError(t, s.Save("a.md", []byte("a")))
+	s.FailNth(storage.OpSave, 2, injected)
+
+	assert.NoError(t, s.Save("b.md", []byte("b")))
+	assert.ErrorIs(t, s.Save("c.md", []byte("c")), injected)
+	// Faults fire once.
+	assert.NoError(t, s.Save("d.md", []byte("d")))
+
+	ok, err := s.Exists("c.md")
+	assert.NoError(t, err)
+	assert.False(t, ok, "a failed save must not store anything")
+	assert.Equal(t, 4, s.Calls(storage.OpSave))
+}
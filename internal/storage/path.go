@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidPath is returned for paths that are not clean, relative,
+// slash-separated paths inside the storage root.
+var ErrInvalidPath = errors.New("invalid path")
+
+// ValidatePath checks that path cannot escape the storage root: it must be
+// relative, use forward slashes, and contain no empty, "." or ".." segments.
+func ValidatePath(path string) error {
+	if path == "" {
+		return invalidPath(path)
+	}
+	return validateSegments(path, strings.Split(path, "/"))
+}
+
+// validatePrefix is ValidatePath for List prefixes, which may be empty, end
+// in a slash, or stop part way through a segment.
+func validatePrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	segments := strings.Split(prefix, "/")
+	if segments[len(segments)-1] == "" {
+		segments = segments[:len(segments)-1]
+	}
+	return validateSegments(prefix, segments)
+}
+
+func validateSegments(path string, segments []string) error {
+	if strings.HasPrefix(path, "/") || filepath.IsAbs(path) || strings.ContainsAny(path, "\\\x00") {
+		return invalidPath(path)
+	}
+	for _, seg := range segments {
+		if seg == "" || seg == "." || seg == ".." {
+			return invalidPath(path)
+		}
+	}
+	return nil
+}
+
+func invalidPath(path string) error {
+	return fmt.Errorf("%q: %w", path, ErrInvalidPath)
+}
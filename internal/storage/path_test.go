@@ -0,0 +1,38 @@
+package storage_test
+
+import (
+	"testing"
+
+	"go-blog/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePath(t *testing.T) {
+	valid := []string{
+		"user_1/post_1_v1.md",
+		"user_1/media/cover.jpg",
+		"file.md",
+		"dir/..hidden/file.md", // dots are only special as a whole segment
+	}
+	for _, path := range valid {
+		assert.NoError(t, storage.ValidatePath(path), path)
+	}
+
+	invalid := []string{
+		"",
+		"/etc/passwd",
+		"..",
+		"../files/user_1/post_1_v1.md",
+		"user_1/../../etc/passwd",
+		"user_1/..",
+		"./user_1/post_1_v1.md",
+		"user_1//post_1_v1.md",
+		"user_1/",
+		`user_1\..\..\etc\passwd`,
+		"user_1/post\x00.md",
+	}
+	for _, path := range invalid {
+		assert.ErrorIs(t, storage.ValidatePath(path), storage.ErrInvalidPath, path)
+	}
+}
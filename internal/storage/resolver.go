@@ -0,0 +1,391 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ErrAccessDenied is returned when a user-scoped storage is asked for a
+// path outside the user's own prefix.
+var ErrAccessDenied = errors.New("access denied")
+
+// Resolver hands out the FileStorage that holds a given user's files.
+type Resolver interface {
+	ForUser(userID int) (FileStorage, error)
+	// All returns every storage the resolver manages, unscoped, for jobs
+	// that work across users such as garbage collection.
+	All() ([]FileStorage, error)
+}
+
+// UserPrefix is the path prefix under which a user's files are stored.
+func UserPrefix(userID int) string {
+	return fmt.Sprintf("user_%d/", userID)
+}
+
+// ownerPattern extracts the owner from a storage path.
+var ownerPattern = regexp.MustCompile(`^user_(\d+)/`)
+
+// Owner returns the ID of the user whose prefix path is under.
+func Owner(path string) (int, bool) {
+	m := ownerPattern.FindStringSubmatch(path)
+	if m == nil {
+		return 0, false
+	}
+	userID, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+// ExistingResolver is implemented by resolvers whose ForUser has side
+// effects, such as creating the user's bucket.
+type ExistingResolver interface {
+	// Existing is ForUser without the side effects. It reports false when
+	// the user's storage doesn't exist yet.
+	Existing(userID int) (FileStorage, bool, error)
+}
+
+// Existing returns userID's storage from r without creating anything, for
+// callers that must not write, such as dry runs.
+func Existing(r Resolver, userID int) (FileStorage, bool, error) {
+	if er, ok := r.(ExistingResolver); ok {
+		return er.Existing(userID)
+	}
+	fs, err := r.ForUser(userID)
+	return fs, err == nil, err
+}
+
+// sharedResolver serves every user from the same storage.
+type sharedResolver struct {
+	storage FileStorage
+}
+
+// NewSharedResolver returns a Resolver that hands out fs for every user.
+// Paths already carry the user ID, so this is the layout used by local
+// development and by a single shared bucket.
+func NewSharedResolver(fs FileStorage) Resolver {
+	return &sharedResolver{storage: fs}
+}
+
+func (r *sharedResolver) ForUser(userID int) (FileStorage, error) {
+	return r.storage, nil
+}
+
+func (r *sharedResolver) All() ([]FileStorage, error) {
+	return []FileStorage{r.storage}, nil
+}
+
+// prefixResolver shares one storage but confines each user to their prefix.
+type prefixResolver struct {
+	storage FileStorage
+}
+
+// NewPrefixResolver returns a Resolver whose storages only accept paths
+// under UserPrefix(userID). It is the in-process counterpart of an IAM
+// policy that limits a principal to arn:aws:s3:::bucket/user_<id>/*, so a
+// bug elsewhere cannot read or overwrite another user's files.
+func NewPrefixResolver(fs FileStorage) Resolver {
+	return &prefixResolver{storage: fs}
+}
+
+func (r *prefixResolver) ForUser(userID int) (FileStorage, error) {
+	return &scopedStorage{base: r.storage, prefix: UserPrefix(userID)}, nil
+}
+
+func (r *prefixResolver) All() ([]FileStorage, error) {
+	return []FileStorage{r.storage}, nil
+}
+
+// scopedStorage rejects every path that doesn't start with prefix.
+type scopedStorage struct {
+	base   FileStorage
+	prefix string
+}
+
+func (s *scopedStorage) check(path string) error {
+	if !strings.HasPrefix(path, s.prefix) {
+		return fmt.Errorf("%q is outside %q: %w", path, s.prefix, ErrAccessDenied)
+	}
+	return nil
+}
+
+func (s *scopedStorage) Save(path string, data []byte) error {
+	if err := s.check(path); err != nil {
+		return err
+	}
+	return s.base.Save(path, data)
+}
+
+func (s *scopedStorage) SaveStream(path string, r io.Reader) error {
+	if err := s.check(path); err != nil {
+		return err
+	}
+	return s.base.SaveStream(path, r)
+}
+
+func (s *scopedStorage) Read(path string) ([]byte, error) {
+	if err := s.check(path); err != nil {
+		return nil, err
+	}
+	return s.base.Read(path)
+}
+
+func (s *scopedStorage) ReadStream(path string) (io.ReadCloser, error) {
+	if err := s.check(path); err != nil {
+		return nil, err
+	}
+	return s.base.ReadStream(path)
+}
+
+func (s *scopedStorage) Delete(path string) error {
+	if err := s.check(path); err != nil {
+		return err
+	}
+	return s.base.Delete(path)
+}
+
+func (s *scopedStorage) Exists(path string) (bool, error) {
+	if err := s.check(path); err != nil {
+		return false, err
+	}
+	return s.base.Exists(path)
+}
+
+func (s *scopedStorage) Stat(path string) (*FileInfo, error) {
+	if err := s.check(path); err != nil {
+		return nil, err
+	}
+	return s.base.Stat(path)
+}
+
+func (s *scopedStorage) URL(path string, ttl time.Duration) (string, error) {
+	if err := s.check(path); err != nil {
+		return "", err
+	}
+	return URL(s.base, path, ttl)
+}
+
+// List narrows prefixes that are broader than the scope, such as "user_",
+// down to the scope itself.
+func (s *scopedStorage) List(prefix string) ([]FileInfo, error) {
+	if strings.HasPrefix(s.prefix, prefix) {
+		prefix = s.prefix
+	}
+	if err := s.check(prefix); err != nil {
+		return nil, err
+	}
+	return s.base.List(prefix)
+}
+
+// BucketResolver gives every user their own S3 bucket, named by filling the
+// user ID into a pattern such as "go-blog-user-%d". Buckets are created the
+// first time a user's storage is requested, and the storage is cached for
+// the life of the process. Requests for a user whose bucket is still being
+// prepared wait for that instead of creating it again; other users are not
+// held up.
+type BucketResolver struct {
+	sess    *session.Session
+	client  *s3.S3
+	pattern string
+	nameRe  *regexp.Regexp
+	sse     ServerSideEncryption
+
+	presignSess *session.Session
+	decorate    Decorator
+
+	mu       sync.Mutex
+	storages map[int]FileStorage
+	pending  map[int]*bucketCall
+}
+
+// bucketCall is a bucket being prepared for ForUser. done is closed once
+// storage or err is set.
+type bucketCall struct {
+	done    chan struct{}
+	storage FileStorage
+	err     error
+}
+
+// NewBucketResolver creates a BucketResolver. pattern must contain exactly
+// one %d, and the names it produces must be valid S3 bucket names. Objects
+// are uploaded with the given server-side encryption.
+func NewBucketResolver(sess *session.Session, pattern string, sse ServerSideEncryption) (*BucketResolver, error) {
+	if strings.Count(pattern, "%d") != 1 || strings.Count(pattern, "%") != 1 {
+		return nil, fmt.Errorf("bucket pattern %q must contain exactly one %%d", pattern)
+	}
+	if err := sse.validate(); err != nil {
+		return nil, err
+	}
+	nameRe := regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(pattern), "%d", `(\d+)`, 1) + "$")
+
+	return &BucketResolver{
+		sess:     sess,
+		client:   s3.New(sess),
+		pattern:  pattern,
+		nameRe:   nameRe,
+		sse:      sse,
+		storages: make(map[int]FileStorage),
+		pending:  make(map[int]*bucketCall),
+	}, nil
+}
+
+// Decorate wraps every storage the resolver creates from now on with d.
+func (r *BucketResolver) Decorate(d Decorator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decorate = d
+}
+
+// PresignWith makes every storage the resolver creates from now on sign
+// URLs against sess; see S3Storage.PresignWith.
+func (r *BucketResolver) PresignWith(sess *session.Session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.presignSess = sess
+}
+
+// BucketName returns the name of the bucket holding userID's files.
+func (r *BucketResolver) BucketName(userID int) string {
+	return fmt.Sprintf(r.pattern, userID)
+}
+
+// ForUser returns userID's storage, creating the bucket if needed. Failures
+// are not cached, so the next call tries again.
+func (r *BucketResolver) ForUser(userID int) (FileStorage, error) {
+	r.mu.Lock()
+	if s, ok := r.storages[userID]; ok {
+		r.mu.Unlock()
+		return s, nil
+	}
+	if call, ok := r.pending[userID]; ok {
+		r.mu.Unlock()
+		<-call.done
+		return call.storage, call.err
+	}
+	call := &bucketCall{done: make(chan struct{})}
+	r.pending[userID] = call
+	r.mu.Unlock()
+
+	// The S3 calls can take seconds, so they run without the lock.
+	bucket := r.BucketName(userID)
+	err := r.ensureBucket(bucket)
+
+	r.mu.Lock()
+	if err != nil {
+		call.err = fmt.Errorf("could not prepare bucket %s: %w", bucket, err)
+	} else {
+		call.storage = r.newStorage(userID, bucket)
+	}
+	delete(r.pending, userID)
+	r.mu.Unlock()
+	close(call.done)
+	return call.storage, call.err
+}
+
+// Existing is like ForUser but never creates the bucket.
+func (r *BucketResolver) Existing(userID int) (FileStorage, bool, error) {
+	r.mu.Lock()
+	s, ok := r.storages[userID]
+	r.mu.Unlock()
+	if ok {
+		return s, true, nil
+	}
+
+	bucket := r.BucketName(userID)
+	exists, err := r.bucketExists(bucket)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// ForUser may have got there first.
+	if s, ok := r.storages[userID]; ok {
+		return s, true, nil
+	}
+	return r.newStorage(userID, bucket), true, nil
+}
+
+// newStorage creates and caches the storage for a bucket that exists. It
+// makes no network calls; the caller must hold r.mu.
+func (r *BucketResolver) newStorage(userID int, bucket string) FileStorage {
+	s3s := NewS3StorageWithSession(r.sess, bucket, r.sse)
+	s3s.PresignWith(r.presignSess)
+	var s FileStorage = s3s
+	if r.decorate != nil {
+		s = r.decorate(bucket, s)
+	}
+	r.storages[userID] = s
+	return s
+}
+
+// All returns a storage for every existing bucket that matches the pattern.
+func (r *BucketResolver) All() ([]FileStorage, error) {
+	out, err := r.client.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	var storages []FileStorage
+	for _, b := range out.Buckets {
+		m := r.nameRe.FindStringSubmatch(aws.StringValue(b.Name))
+		if m == nil {
+			continue
+		}
+		userID, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		s, err := r.ForUser(userID)
+		if err != nil {
+			return nil, err
+		}
+		storages = append(storages, s)
+	}
+	return storages, nil
+}
+
+// bucketExists reports whether bucket exists.
+func (r *BucketResolver) bucketExists(bucket string) (bool, error) {
+	_, err := r.client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return true, nil
+	}
+	if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == "NotFound" || aerr.Code() == s3.ErrCodeNoSuchBucket) {
+		return false, nil
+	}
+	return false, err
+}
+
+// ensureBucket creates bucket unless it already exists.
+func (r *BucketResolver) ensureBucket(bucket string) error {
+	exists, err := r.bucketExists(bucket)
+	if err != nil || exists {
+		return err
+	}
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	// us-east-1 is the default location and must not be named explicitly.
+	if region := aws.StringValue(r.sess.Config.Region); region != "" && region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{LocationConstraint: aws.String(region)}
+	}
+	if _, err := r.client.CreateBucket(input); err != nil {
+		// Another process may have won the race to create it.
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != s3.ErrCodeBucketAlreadyOwnedByYou {
+			return err
+		}
+	}
+	return r.client.WaitUntilBucketExists(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+}
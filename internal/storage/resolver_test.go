@@ -0,0 +1,202 @@
+package storage_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-blog/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedResolver(t *testing.T) {
+	fs := storage.NewMemoryStorage()
+	r := storage.NewSharedResolver(fs)
+
+	one, err := r.ForUser(1)
+	require.NoError(t, err)
+	two, err := r.ForUser(2)
+	require.NoError(t, err)
+	assert.Same(t, fs, one)
+	assert.Same(t, fs, two)
+
+	all, err := r.All()
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
+
+func TestPrefixResolver(t *testing.T) {
+	fs := storage.NewMemoryStorage()
+	require.NoError(t, fs.Save("user_2/post_1_v1.md", []byte("theirs")))
+	r := storage.NewPrefixResolver(fs)
+
+	mine, err := r.ForUser(1)
+	require.NoError(t, err)
+
+	assert.NoError(t, mine.Save("user_1/post_2_v1.md", []byte("mine")))
+
+	_, err = mine.Read("user_2/post_1_v1.md")
+	assert.ErrorIs(t, err, storage.ErrAccessDenied)
+	assert.ErrorIs(t, mine.Save("user_2/post_1_v1.md", []byte("overwrite")), storage.ErrAccessDenied)
+	assert.ErrorIs(t, mine.Delete("user_2/post_1_v1.md"), storage.ErrAccessDenied)
+	// "user_10/" starts with "user_1" but is someone else's prefix.
+	assert.ErrorIs(t, mine.Save("user_10/post_3_v1.md", []byte("x")), storage.ErrAccessDenied)
+
+	// A broad listing is narrowed to the user's own files.
+	files, err := mine.List("user_")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "user_1/post_2_v1.md", files[0].Path)
+
+	_, err = mine.List("user_2/")
+	assert.ErrorIs(t, err, storage.ErrAccessDenied)
+
+	// The unscoped storage used by maintenance jobs still sees everything.
+	all, err := r.All()
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	files, err = all[0].List("user_")
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+// TestBucketResolver creates real buckets and is skipped unless
+// S3_TEST_BUCKET_PATTERN is set, e.g. to "go-blog-test-%d". The buckets it
+// creates are not removed.
+func TestBucketResolver(t *testing.T) {
+	pattern := os.Getenv("S3_TEST_BUCKET_PATTERN")
+	if pattern == "" {
+		t.Skip("S3_TEST_BUCKET_PATTERN not set")
+	}
+
+	sess, err := storage.NewS3Session(s3TestOptions())
+	require.NoError(t, err)
+	r, err := storage.NewBucketResolver(sess, pattern, storage.ServerSideEncryption{})
+	require.NoError(t, err)
+
+	userID := int(time.Now().Unix() % 100000)
+	path := fmt.Sprintf("user_%d/post_1_v1.md", userID)
+
+	fs, err := r.ForUser(userID)
+	require.NoError(t, err)
+	require.NoError(t, fs.Save(path, []byte("hello")))
+	t.Cleanup(func() { fs.Delete(path) })
+
+	// The storage is cached and reused.
+	again, err := r.ForUser(userID)
+	require.NoError(t, err)
+	assert.Same(t, fs, again)
+
+	all, err := r.All()
+	require.NoError(t, err)
+	assert.Contains(t, all, fs)
+}
+
+// fakeBucketServer answers HeadBucket and CreateBucket. Creating a bucket
+// named in hold blocks until hold is closed.
+type fakeBucketServer struct {
+	mu      sync.Mutex
+	buckets map[string]bool
+	creates map[string]int
+	started chan string
+	hold    map[string]chan struct{}
+}
+
+func (f *fakeBucketServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	bucket := strings.Trim(req.URL.Path, "/")
+	switch req.Method {
+	case http.MethodHead:
+		f.mu.Lock()
+		exists := f.buckets[bucket]
+		f.mu.Unlock()
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case http.MethodPut:
+		f.mu.Lock()
+		f.creates[bucket]++
+		hold := f.hold[bucket]
+		f.mu.Unlock()
+		f.started <- bucket
+		if hold != nil {
+			<-hold
+		}
+		f.mu.Lock()
+		f.buckets[bucket] = true
+		f.mu.Unlock()
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestBucketResolver_ConcurrentCreate(t *testing.T) {
+	release := make(chan struct{})
+	fake := &fakeBucketServer{
+		buckets: map[string]bool{},
+		creates: map[string]int{},
+		started: make(chan string, 10),
+		hold:    map[string]chan struct{}{"user-1": release},
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	sess, err := storage.NewS3Session(storage.S3Options{
+		Endpoint:        srv.URL,
+		ForcePathStyle:  true,
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	r, err := storage.NewBucketResolver(sess, "user-%d", storage.ServerSideEncryption{})
+	require.NoError(t, err)
+
+	results := make([]storage.FileStorage, 5)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fs, err := r.ForUser(1)
+			assert.NoError(t, err)
+			results[i] = fs
+		}()
+	}
+	require.Equal(t, "user-1", <-fake.started)
+
+	// Another user's bucket is not held up by the one being created.
+	_, err = r.ForUser(2)
+	require.NoError(t, err)
+	require.Equal(t, "user-2", <-fake.started)
+
+	close(release)
+	wg.Wait()
+	for _, fs := range results {
+		assert.Same(t, results[0], fs)
+	}
+	assert.Equal(t, 1, fake.creates["user-1"])
+}
+
+func TestNewBucketResolver_InvalidPattern(t *testing.T) {
+	_, err := storage.NewBucketResolver(nil, "go-blog-user", storage.ServerSideEncryption{})
+	assert.Error(t, err)
+	_, err = storage.NewBucketResolver(nil, "go-blog-%s-%d", storage.ServerSideEncryption{})
+	assert.Error(t, err)
+}
+
+func TestOwner(t *testing.T) {
+	userID, ok := storage.Owner("user_12/media/a.png")
+	assert.True(t, ok)
+	assert.Equal(t, 12, userID)
+
+	for _, path := range []string{"media/a.png", "user_/a.png", "user_x/a.png", "xuser_1/a.png"} {
+		_, ok := storage.Owner(path)
+		assert.False(t, ok, path)
+	}
+}
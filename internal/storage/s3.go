@@ -2,9 +2,22 @@ package storage
 
 import (
 	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go-blog/internal/config"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -12,8 +25,87 @@ import (
 
 type S3Storage struct {
 	bucket     string
+	sse        ServerSideEncryption
 	uploader   *s3manager.Uploader
 	downloader *s3.S3
+	// presigner signs download URLs. It differs from downloader when
+	// browsers reach the server at another address than the app does.
+	presigner *s3.S3
+}
+
+// ServerSideEncryption asks S3 to encrypt uploaded objects at rest.
+type ServerSideEncryption struct {
+	// Mode is "" (the bucket default), "AES256" for SSE-S3 or "aws:kms"
+	// for SSE-KMS.
+	Mode string
+	// KMSKeyID selects the KMS key for "aws:kms". The account's default
+	// S3 key is used when it is empty.
+	KMSKeyID string
+}
+
+func (e ServerSideEncryption) validate() error {
+	switch e.Mode {
+	case "", s3.ServerSideEncryptionAes256:
+		if e.KMSKeyID != "" {
+			return errors.New("a KMS key ID requires aws:kms server-side encryption")
+		}
+		return nil
+	case s3.ServerSideEncryptionAwsKms:
+		return nil
+	default:
+		return fmt.Errorf("unknown server-side encryption %q", e.Mode)
+	}
+}
+
+// apply sets the encryption headers on an upload.
+func (e ServerSideEncryption) apply(input *s3manager.UploadInput) {
+	if e.Mode != "" {
+		input.ServerSideEncryption = aws.String(e.Mode)
+	}
+	if e.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(e.KMSKeyID)
+	}
+}
+
+// S3Options configures the connection to S3 or an S3-compatible server such
+// as MinIO or localstack. The zero value talks to AWS using the default
+// credential chain.
+type S3Options struct {
+	Region string
+	// Endpoint overrides the AWS endpoint, e.g. "http://localhost:9000".
+	Endpoint string
+	// PublicEndpoint is the address browsers use to reach Endpoint, for
+	// when the app talks to the server over a private network, e.g.
+	// "http://minio:9000" inside Docker. Presigned URLs point at it.
+	PublicEndpoint string
+	// ForcePathStyle addresses buckets as endpoint/bucket instead of
+	// bucket.endpoint, which most self-hosted servers require.
+	ForcePathStyle bool
+	// Static credentials. When empty the default AWS credential chain
+	// (environment, shared config, instance role) is used.
+	AccessKeyID     string
+	SecretAccessKey string
+	// DisableSSL talks plain HTTP when Endpoint has no scheme.
+	DisableSSL bool
+	// InsecureSkipVerify accepts self-signed certificates. Never use it
+	// against AWS itself.
+	InsecureSkipVerify bool
+	SSE                ServerSideEncryption
+}
+
+// S3OptionsFromConfig builds S3Options from the application configuration.
+func S3OptionsFromConfig(cfg *config.Config) S3Options {
+	return S3Options{
+		Region:             cfg.S3Region,
+		Endpoint:           cfg.S3Endpoint,
+		PublicEndpoint:     cfg.S3PublicEndpoint,
+		ForcePathStyle:     cfg.S3ForcePathStyle,
+		AccessKeyID:        cfg.AWSAccessKey,
+		SecretAccessKey:    cfg.AWSSecretKey,
+		DisableSSL:         cfg.S3DisableSSL,
+		InsecureSkipVerify: cfg.S3InsecureSkipVerify,
+		SSE:                ServerSideEncryption{Mode: cfg.S3SSE, KMSKeyID: cfg.S3SSEKMSKeyID},
+	}
 }
 
 // NewS3Storage creates a new S3 storage client.
@@ -22,38 +114,234 @@ type S3Storage struct {
 // A better, more scalable approach is to use a single bucket with user-specific prefixes (folders),
 // e.g., "user_123/post_abc.md". This implementation uses a single bucket name provided
 // via configuration and assumes paths will contain user-specific identifiers.
-func NewS3Storage(bucket, region string) (*S3Storage, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region)},
-	)
+// BucketResolver provides the bucket-per-user layout for deployments that need it.
+func NewS3Storage(bucket string, opts S3Options) (*S3Storage, error) {
+	if err := opts.SSE.validate(); err != nil {
+		return nil, err
+	}
+	sess, err := NewS3Session(opts)
+	if err != nil {
+		return nil, err
+	}
+	presignSess, err := NewS3PresignSession(opts)
 	if err != nil {
 		return nil, err
 	}
+	s := NewS3StorageWithSession(sess, bucket, opts.SSE)
+	s.PresignWith(presignSess)
+	return s, nil
+}
+
+// NewS3Session creates the AWS session shared by S3 storages.
+func NewS3Session(opts S3Options) (*session.Session, error) {
+	awsCfg := &aws.Config{
+		Region:           aws.String(opts.Region),
+		S3ForcePathStyle: aws.Bool(opts.ForcePathStyle),
+		DisableSSL:       aws.Bool(opts.DisableSSL),
+	}
+	if opts.Endpoint != "" {
+		awsCfg.Endpoint = aws.String(opts.Endpoint)
+		// S3-compatible servers ignore the region, but the SDK insists on one.
+		if opts.Region == "" {
+			awsCfg.Region = aws.String("us-east-1")
+		}
+	}
+	if opts.AccessKeyID != "" {
+		awsCfg.Credentials = credentials.NewStaticCredentials(opts.AccessKeyID, opts.SecretAccessKey, "")
+	}
+	if opts.InsecureSkipVerify {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		awsCfg.HTTPClient = &http.Client{Transport: transport}
+	}
+	return session.NewSession(awsCfg)
+}
+
+// NewS3PresignSession creates the session presigned URLs are signed with
+// when opts has a PublicEndpoint, and returns nil otherwise. Signing needs
+// no network access, so the endpoint doesn't have to be reachable from here.
+func NewS3PresignSession(opts S3Options) (*session.Session, error) {
+	if opts.PublicEndpoint == "" {
+		return nil, nil
+	}
+	opts.Endpoint = opts.PublicEndpoint
+	return NewS3Session(opts)
+}
 
+// NewS3StorageWithSession creates an S3 storage for bucket on an existing
+// session, so many storages can share one set of connections.
+func NewS3StorageWithSession(sess *session.Session, bucket string, sse ServerSideEncryption) *S3Storage {
+	client := s3.New(sess)
 	return &S3Storage{
 		bucket:     bucket,
+		sse:        sse,
 		uploader:   s3manager.NewUploader(sess),
-		downloader: s3.New(sess),
-	}, nil
+		downloader: client,
+		presigner:  client,
+	}
+}
+
+// PresignWith makes URL sign against sess instead of the session the
+// storage talks to. A nil session is ignored.
+func (s *S3Storage) PresignWith(sess *session.Session) {
+	if sess != nil {
+		s.presigner = s3.New(sess)
+	}
 }
 
 func (s *S3Storage) Save(path string, data []byte) error {
-	_, err := s.uploader.Upload(&s3manager.UploadInput{
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	input := &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(path),
+		Body:        bytes.NewReader(data),
+		ContentType: contentType(path),
+	}
+	s.sse.apply(input)
+	_, err := s.uploader.Upload(input)
+	return err
+}
+
+func (s *S3Storage) Read(path string) ([]byte, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	result, err := s.downloader.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(path),
-		Body:   bytes.NewReader(data),
 	})
+	if err != nil {
+		return nil, s.wrapErr(path, err)
+	}
+	defer result.Body.Close()
+	return ioutil.ReadAll(result.Body)
+}
+
+func (s *S3Storage) SaveStream(path string, r io.Reader) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	// The uploader reads r in parts and switches to a multipart upload for
+	// large bodies, so only a few parts are buffered at any time.
+	input := &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(path),
+		Body:        r,
+		ContentType: contentType(path),
+	}
+	s.sse.apply(input)
+	_, err := s.uploader.Upload(input)
 	return err
 }
 
-func (s *S3Storage) Read(path string) ([]byte, error) {
+func (s *S3Storage) ReadStream(path string) (io.ReadCloser, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
 	result, err := s.downloader.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(path),
 	})
 	if err != nil {
+		return nil, s.wrapErr(path, err)
+	}
+	return result.Body, nil
+}
+
+func (s *S3Storage) Delete(path string) error {
+	if err := ValidatePath(path); err != nil {
+		return err
+	}
+	// S3 treats deleting a missing key as a success.
+	_, err := s.downloader.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path),
+	})
+	return err
+}
+
+func (s *S3Storage) List(prefix string) ([]FileInfo, error) {
+	if err := validatePrefix(prefix); err != nil {
 		return nil, err
 	}
-	defer result.Body.Close()
-	return ioutil.ReadAll(result.Body)
-}
\ No newline at end of file
+	var files []FileInfo
+	err := s.downloader.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			files = append(files, FileInfo{
+				Path:    aws.StringValue(obj.Key),
+				Size:    aws.Int64Value(obj.Size),
+				ModTime: aws.TimeValue(obj.LastModified),
+				ETag:    strings.Trim(aws.StringValue(obj.ETag), `"`),
+			})
+		}
+		return true
+	})
+	return files, err
+}
+
+func (s *S3Storage) Exists(path string) (bool, error) {
+	_, err := s.Stat(path)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *S3Storage) Stat(path string) (*FileInfo, error) {
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	head, err := s.downloader.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path),
+	})
+	if err != nil {
+		return nil, s.wrapErr(path, err)
+	}
+	return &FileInfo{
+		Path:    path,
+		Size:    aws.Int64Value(head.ContentLength),
+		ModTime: aws.TimeValue(head.LastModified),
+		ETag:    strings.Trim(aws.StringValue(head.ETag), `"`),
+	}, nil
+}
+
+// wrapErr maps S3's "missing key" errors onto ErrNotFound. GetObject reports
+// NoSuchKey, while HeadObject has no body and only reports NotFound.
+func (s *S3Storage) wrapErr(path string, err error) error {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return fmt.Errorf("%s: %w", path, ErrNotFound)
+		}
+	}
+	return err
+}
+
+// URL returns a presigned GET URL for path.
+func (s *S3Storage) URL(path string, ttl time.Duration) (string, error) {
+	if err := ValidatePath(path); err != nil {
+		return "", err
+	}
+	req, _ := s.presigner.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path),
+	})
+	return req.Presign(ttl)
+}
+
+// contentType guesses the Content-Type to store with an object, so that
+// presigned URLs are served with it. S3 falls back to
+// binary/octet-stream when it is nil.
+func contentType(path string) *string {
+	t := mime.TypeByExtension(filepath.Ext(path))
+	if t == "" {
+		return nil
+	}
+	return aws.String(t)
+}
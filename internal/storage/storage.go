@@ -1,14 +1,45 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"go-blog/internal/config"
+	"io"
+	"time"
 )
 
+// ErrNotFound is returned when a file does not exist.
+var ErrNotFound = errors.New("file not found")
+
+// FileInfo describes a stored file. ETag is a checksum of the content: the
+// hex MD5 for local files and the object ETag for S3, which is also the MD5
+// for objects uploaded in a single part. List may leave it empty when
+// computing it would mean reading every file.
+type FileInfo struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	ETag    string    `json:"etag,omitempty"`
+}
+
 // FileStorage defines the interface for file storage operations.
+// Paths are slash-separated and relative to the root of the storage.
+// Read and Stat return an error wrapping ErrNotFound for missing files.
 type FileStorage interface {
 	Save(path string, data []byte) error
 	Read(path string) ([]byte, error)
+	// SaveStream is like Save but copies the content from r, so large files
+	// never have to be held in memory.
+	SaveStream(path string, r io.Reader) error
+	// ReadStream is like Read but returns a reader over the content.
+	// The caller must close it.
+	ReadStream(path string) (io.ReadCloser, error)
+	// Delete removes the file at path. Deleting a missing file is not an error.
+	Delete(path string) error
+	Exists(path string) (bool, error)
+	Stat(path string) (*FileInfo, error)
+	// List returns every file whose path starts with prefix.
+	List(prefix string) ([]FileInfo, error)
 }
 
 // New creates a new FileStorage instance based on the configuration.
@@ -19,10 +50,109 @@ func New(cfg *config.Config) (FileStorage, error) {
 		if err != nil {
 			return nil, err
 		}
+		// Without a signing key files are streamed by the app instead.
+		if signer, err := LocalURLSigner(cfg); err == nil {
+			storage.SignURLs(signer)
+		}
 		return storage, nil
 	case "s3":
-		return NewS3Storage(cfg.S3Bucket, cfg.S3Region)
+		return NewS3Storage(cfg.S3Bucket, S3OptionsFromConfig(cfg))
+	case "memory":
+		// Files are lost when the process exits; meant for tests and demos.
+		return NewMemoryStorage(), nil
 	default:
 		return nil, fmt.Errorf("unknown storage type: %s", cfg.StorageType)
 	}
-}
\ No newline at end of file
+}
+
+// Decorator wraps a storage with extra behaviour, such as caching. name
+// identifies the storage being wrapped, e.g. its bucket.
+type Decorator func(name string, fs FileStorage) FileStorage
+
+// NewResolver creates the Resolver selected by cfg.StorageIsolation:
+//   - "shared" (the default) serves every user from the storage built by New.
+//   - "prefix" does the same but confines each user to their user_<id>/ prefix.
+//   - "bucket" gives every user their own S3 bucket named by cfg.S3BucketPattern.
+//
+// Every storage it hands out is wrapped by the decorators cfg enables.
+func NewResolver(cfg *config.Config) (Resolver, error) {
+	decorate, err := decorator(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.StorageIsolation {
+	case "", "shared", "prefix":
+		fs, err := New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		fs = decorate("", fs)
+		if cfg.StorageIsolation == "prefix" {
+			return NewPrefixResolver(fs), nil
+		}
+		return NewSharedResolver(fs), nil
+	case "bucket":
+		if cfg.StorageType != "s3" {
+			return nil, fmt.Errorf("bucket isolation requires s3 storage, got %s", cfg.StorageType)
+		}
+		opts := S3OptionsFromConfig(cfg)
+		sess, err := NewS3Session(opts)
+		if err != nil {
+			return nil, err
+		}
+		presignSess, err := NewS3PresignSession(opts)
+		if err != nil {
+			return nil, err
+		}
+		r, err := NewBucketResolver(sess, cfg.S3BucketPattern, opts.SSE)
+		if err != nil {
+			return nil, err
+		}
+		r.PresignWith(presignSess)
+		r.Decorate(decorate)
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unknown storage isolation: %s", cfg.StorageIsolation)
+	}
+}
+
+// decorator builds the single Decorator applied to every storage, so that
+// state such as the cache is shared between them. Decorators are applied in
+// order, so the last one added sees data first when it is written.
+func decorator(cfg *config.Config) (Decorator, error) {
+	var decorators []Decorator
+
+	if cfg.StorageCacheSize > 0 || cfg.StorageCacheDir != "" {
+		cache, err := NewCache(cfg.StorageCacheSize, cfg.StorageCacheDir, cfg.StorageCacheDiskSize)
+		if err != nil {
+			return nil, fmt.Errorf("could not create storage cache: %w", err)
+		}
+		decorators = append(decorators, cache.Wrap)
+	}
+
+	// Encryption wraps the cache, so the cache only ever holds ciphertext.
+	if cfg.StorageEncryptionKeys != "" {
+		keys, err := ParseKeyring(cfg.StorageEncryptionKeys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid STORAGE_ENCRYPTION_KEYS: %w", err)
+		}
+		decorators = append(decorators, keys.Wrap)
+	}
+
+	// Compression has to see the plaintext, so it wraps encryption.
+	if cfg.StorageCompression != "" {
+		compressor, err := NewCompressor(cfg.StorageCompression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid STORAGE_COMPRESSION: %w", err)
+		}
+		decorators = append(decorators, compressor.Wrap)
+	}
+
+	return func(name string, fs FileStorage) FileStorage {
+		for _, d := range decorators {
+			fs = d(name, fs)
+		}
+		return fs
+	}, nil
+}
@@ -0,0 +1,209 @@
+// Package storagetest is a conformance suite for storage.FileStorage
+// implementations. Every backend's tests call Run so they all behave the
+// same way as far as the rest of the application can tell.
+package storagetest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	"go-blog/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run runs the conformance suite against the storage returned by newStorage.
+// Every test works below its own unique prefix and cleans up after itself,
+// so the storage may be shared, e.g. a real S3 bucket.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.FileStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.FileStorage, root string)
+	}{
+		{"SaveAndRead", testSaveAndRead},
+		{"Overwrite", testOverwrite},
+		{"SaveStreamAndReadStream", testStreams},
+		{"ReadStreamMissing", testReadStreamMissing},
+		{"ReadMissing", testReadMissing},
+		{"Exists", testExists},
+		{"Stat", testStat},
+		{"StatMissing", testStatMissing},
+		{"Delete", testDelete},
+		{"DeleteMissing", testDeleteMissing},
+		{"List", testList},
+		{"InvalidPaths", testInvalidPaths},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newStorage(t)
+			root := fmt.Sprintf("storagetest-%d", time.Now().UnixNano())
+			t.Cleanup(func() {
+				files, _ := s.List(root + "/")
+				for _, f := range files {
+					s.Delete(f.Path)
+				}
+			})
+			tc.fn(t, s, root)
+		})
+	}
+}
+
+func testSaveAndRead(t *testing.T, s storage.FileStorage, root string) {
+	path := root + "/user_1/post_1_v1.md"
+	content := []byte("# Hello\n\nSome *markdown*.\n")
+
+	require.NoError(t, s.Save(path, content))
+
+	data, err := s.Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func testOverwrite(t *testing.T, s storage.FileStorage, root string) {
+	path := root + "/file.md"
+
+	require.NoError(t, s.Save(path, []byte("first version, which is longer")))
+	require.NoError(t, s.Save(path, []byte("second")))
+
+	data, err := s.Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), data)
+}
+
+func testStreams(t *testing.T, s storage.FileStorage, root string) {
+	path := root + "/user_1/media/large.bin"
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // 1 MiB
+
+	require.NoError(t, s.SaveStream(path, bytes.NewReader(content)))
+
+	r, err := s.ReadStream(path)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	// Streams and byte slices address the same files.
+	data, err = s.Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, len(content), len(data))
+}
+
+func testReadStreamMissing(t *testing.T, s storage.FileStorage, root string) {
+	_, err := s.ReadStream(root + "/missing.md")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testReadMissing(t *testing.T, s storage.FileStorage, root string) {
+	_, err := s.Read(root + "/missing.md")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testExists(t *testing.T, s storage.FileStorage, root string) {
+	path := root + "/dir/file.md"
+
+	ok, err := s.Exists(path)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Save(path, []byte("content")))
+
+	ok, err = s.Exists(path)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// A directory-like prefix is not a file.
+	ok, err = s.Exists(root + "/dir")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func testStat(t *testing.T, s storage.FileStorage, root string) {
+	path := root + "/file.md"
+	content := []byte("some content to checksum")
+	sum := md5.Sum(content)
+
+	before := time.Now().Add(-time.Minute)
+	require.NoError(t, s.Save(path, content))
+
+	info, err := s.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, path, info.Path)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.Equal(t, hex.EncodeToString(sum[:]), info.ETag)
+	assert.True(t, info.ModTime.After(before), "mod time %v should be recent", info.ModTime)
+}
+
+func testStatMissing(t *testing.T, s storage.FileStorage, root string) {
+	_, err := s.Stat(root + "/missing.md")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testDelete(t *testing.T, s storage.FileStorage, root string) {
+	path := root + "/file.md"
+	require.NoError(t, s.Save(path, []byte("content")))
+
+	require.NoError(t, s.Delete(path))
+
+	ok, err := s.Exists(path)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, err = s.Read(path)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testDeleteMissing(t *testing.T, s storage.FileStorage, root string) {
+	assert.NoError(t, s.Delete(root+"/missing.md"))
+}
+
+func testList(t *testing.T, s storage.FileStorage, root string) {
+	for _, p := range []string{"user_1/post_1_v1.md", "user_1/post_1_v2.md", "user_12/post_2_v1.md", "user_2/post_3_v1.md"} {
+		require.NoError(t, s.Save(root+"/"+p, []byte(p)))
+	}
+
+	files, err := s.List(root + "/user_1")
+	require.NoError(t, err)
+
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+		assert.Equal(t, int64(len(f.Path)-len(root)-1), f.Size)
+	}
+	sort.Strings(paths)
+	assert.Equal(t, []string{
+		root + "/user_1/post_1_v1.md",
+		root + "/user_1/post_1_v2.md",
+		root + "/user_12/post_2_v1.md",
+	}, paths)
+
+	files, err = s.List(root + "/nothing-here/")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func testInvalidPaths(t *testing.T, s storage.FileStorage, root string) {
+	for _, path := range []string{"", "/etc/passwd", "../outside.md", root + "/../../outside.md", root + "//file.md", "./" + root, root + `\..\file.md`} {
+		assert.ErrorIs(t, s.Save(path, []byte("x")), storage.ErrInvalidPath, "Save(%q)", path)
+		assert.ErrorIs(t, s.SaveStream(path, bytes.NewReader([]byte("x"))), storage.ErrInvalidPath, "SaveStream(%q)", path)
+		_, err := s.Read(path)
+		assert.ErrorIs(t, err, storage.ErrInvalidPath, "Read(%q)", path)
+		_, err = s.ReadStream(path)
+		assert.ErrorIs(t, err, storage.ErrInvalidPath, "ReadStream(%q)", path)
+		_, err = s.Exists(path)
+		assert.ErrorIs(t, err, storage.ErrInvalidPath, "Exists(%q)", path)
+		_, err = s.Stat(path)
+		assert.ErrorIs(t, err, storage.ErrInvalidPath, "Stat(%q)", path)
+		assert.ErrorIs(t, s.Delete(path), storage.ErrInvalidPath, "Delete(%q)", path)
+	}
+
+	_, err := s.List("../")
+	assert.ErrorIs(t, err, storage.ErrInvalidPath)
+}
@@ -0,0 +1,79 @@
+package storage_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go-blog/internal/config"
+	"go-blog/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHMACSigner(t *testing.T) {
+	signer := storage.NewHMACSigner("/files/", []byte("secret"))
+
+	signed := signer.Sign("user_1/media/a b.png", time.Minute)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "/files/user_1/media/a b.png", u.Path)
+
+	path := strings.TrimPrefix(u.Path, "/files/")
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	assert.NoError(t, signer.Verify(path, expires, signature))
+
+	// A signature is only good for the path it was made for...
+	assert.ErrorIs(t, signer.Verify("user_2/media/a b.png", expires, signature), storage.ErrInvalidSignature)
+	// ...and its expiry can't be extended.
+	assert.ErrorIs(t, signer.Verify(path, "9999999999", signature), storage.ErrInvalidSignature)
+	// Other keys don't verify it.
+	other := storage.NewHMACSigner("/files/", []byte("other"))
+	assert.ErrorIs(t, other.Verify(path, expires, signature), storage.ErrInvalidSignature)
+}
+
+func TestHMACSigner_Expired(t *testing.T) {
+	signer := storage.NewHMACSigner("/files/", []byte("secret"))
+
+	u, err := url.Parse(signer.Sign("user_1/post_1_v1.md", -time.Minute))
+	require.NoError(t, err)
+	err = signer.Verify("user_1/post_1_v1.md", u.Query().Get("expires"), u.Query().Get("signature"))
+	assert.ErrorIs(t, err, storage.ErrInvalidSignature)
+}
+
+func TestURL(t *testing.T) {
+	_, err := storage.URL(storage.NewMemoryStorage(), "user_1/post_1_v1.md", time.Minute)
+	assert.ErrorIs(t, err, storage.ErrURLNotSupported)
+
+	local, err := storage.NewLocalStorage(t.TempDir())
+	require.NoError(t, err)
+	_, err = storage.URL(local, "user_1/post_1_v1.md", time.Minute)
+	assert.ErrorIs(t, err, storage.ErrURLNotSupported, "signing is opt-in")
+
+	local.SignURLs(storage.NewHMACSigner("/files/", []byte("secret")))
+	signed, err := storage.URL(local, "user_1/post_1_v1.md", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "/files/user_1/post_1_v1.md?"))
+
+	_, err = storage.URL(local, "../etc/passwd", time.Minute)
+	assert.ErrorIs(t, err, storage.ErrInvalidPath)
+
+	// Scoped storages only sign their own paths.
+	scoped, err := storage.NewPrefixResolver(local).ForUser(1)
+	require.NoError(t, err)
+	_, err = storage.URL(scoped, "user_1/post_1_v1.md", time.Minute)
+	assert.NoError(t, err)
+	_, err = storage.URL(scoped, "user_2/post_1_v1.md", time.Minute)
+	assert.ErrorIs(t, err, storage.ErrAccessDenied)
+}
+
+func TestLocalURLSigner(t *testing.T) {
+	_, err := storage.LocalURLSigner(&config.Config{JWTSecret: "a-very-secret-key-that-should-be-changed"})
+	assert.ErrorIs(t, err, storage.ErrNoURLSecret, "never falls back to the JWT secret")
+
+	signer, err := storage.LocalURLSigner(&config.Config{FileURLSecret: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, storage.LocalURLPrefix, signer.Prefix())
+}
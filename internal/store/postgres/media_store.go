@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"database/sql"
+	"go-blog/internal/model"
+)
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+func NewMediaStore(db *sql.DB) *MediaStore {
+	return &MediaStore{db: db}
+}
+
+const mediaColumns = `id, user_id, path, content_type, size, original_name, COALESCE(width, 0), COALESCE(height, 0), created_at`
+
+// Create inserts a media item together with its variants.
+func (s *MediaStore) Create(media *model.Media) (*model.Media, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO media (user_id, path, content_type, size, original_name, width, height) VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0)) RETURNING id, created_at`
+	err = tx.QueryRow(query, media.UserID, media.Path, media.ContentType, media.Size, media.OriginalName, media.Width, media.Height).Scan(&media.ID, &media.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range media.Variants {
+		query := `INSERT INTO media_variants (media_id, name, path, width, height, size) VALUES ($1, $2, $3, $4, $5, $6)`
+		if _, err := tx.Exec(query, media.ID, v.Name, v.Path, v.Width, v.Height, v.Size); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return media, nil
+}
+
+// GetByID returns a media item including its variants, smallest first.
+func (s *MediaStore) GetByID(id int) (*model.Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE id = $1`
+	media, err := scanMedia(s.db.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT name, path, width, height, size FROM media_variants WHERE media_id = $1 ORDER BY width`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v model.MediaVariant
+		if err := rows.Scan(&v.Name, &v.Path, &v.Width, &v.Height, &v.Size); err != nil {
+			return nil, err
+		}
+		media.Variants = append(media.Variants, v)
+	}
+	return media, rows.Err()
+}
+
+// GetByPath returns the media item stored at path, or the one that has a
+// variant stored there, including its variants.
+func (s *MediaStore) GetByPath(path string) (*model.Media, error) {
+	query := `
+		SELECT id FROM media WHERE path = $1
+		UNION ALL
+		SELECT media_id FROM media_variants WHERE path = $1
+		LIMIT 1`
+
+	var id int
+	if err := s.db.QueryRow(query, path).Scan(&id); err != nil {
+		return nil, err
+	}
+	return s.GetByID(id)
+}
+
+// ListByUser returns a user's media, newest first. Variants are not loaded.
+func (s *MediaStore) ListByUser(userID int) ([]*model.Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var media []*model.Media
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
+
+func (s *MediaStore) ListPaths() ([]string, error) {
+	query := `
+		SELECT path FROM media
+		UNION
+		SELECT path FROM media_variants`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+func scanMedia(row rowScanner) (*model.Media, error) {
+	m := &model.Media{}
+	err := row.Scan(&m.ID, &m.UserID, &m.Path, &m.ContentType, &m.Size, &m.OriginalName, &m.Width, &m.Height, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
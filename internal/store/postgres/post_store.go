@@ -2,13 +2,18 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"go-blog/internal/model"
+	"go-blog/internal/store"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type PostStore struct {
-	db *sql.DB
+	db dbtx
 }
 
 func NewPostStore(db *sql.DB) *PostStore {
@@ -16,8 +21,8 @@ func NewPostStore(db *sql.DB) *PostStore {
 }
 
 func (s *PostStore) Create(post *model.Post) (*model.Post, error) {
-	query := `INSERT INTO posts (user_id, title, sub_title, image, tags, version) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at, updated_at`
-	err := s.db.QueryRow(query, post.UserID, post.Title, post.SubTitle, post.Image, pq.Array(post.Tags), post.Version).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+	query := `INSERT INTO posts (user_id, title, sub_title, image, tags, version, status, published_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at, updated_at`
+	err := s.db.QueryRow(query, post.UserID, post.Title, post.SubTitle, post.Image, pq.Array(post.Tags), post.Version, post.Status, post.PublishedAt).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -30,13 +35,23 @@ func (s *PostStore) Update(post *model.Post) (*model.Post, error) {
 	return post, err
 }
 
-func (s *PostStore) GetByID(id int) (*model.Post, error) {
+// postColumns is shared by the queries that return posts, in the order
+// scanPost reads them.
+const postColumns = `id, user_id, title, COALESCE(slug, ''), sub_title, image, tags, content_path, version, created_at, updated_at, status, published_at, deleted_at`
+
+// publicCondition matches the posts anyone may see, as model.Post.IsPublic.
+const publicCondition = `(status = 'published' OR (status = 'scheduled' AND published_at <= NOW()))`
+
+// scanPost reads a row of postColumns, followed by any extra columns into
+// extra.
+func scanPost(row rowScanner, extra ...interface{}) (*model.Post, error) {
 	post := &model.Post{}
-	query := `SELECT id, user_id, title, sub_title, image, tags, content_path, version, created_at, updated_at FROM posts WHERE id = $1`
-	err := s.db.QueryRow(query, id).Scan(
+	var publishedAt, deletedAt sql.NullTime
+	dest := []interface{}{
 		&post.ID,
 		&post.UserID,
 		&post.Title,
+		&post.Slug,
 		&post.SubTitle,
 		&post.Image,
 		pq.Array(&post.Tags),
@@ -44,61 +59,419 @@ func (s *PostStore) GetByID(id int) (*model.Post, error) {
 		&post.Version,
 		&post.CreatedAt,
 		&post.UpdatedAt,
-	)
+		&post.Status,
+		&publishedAt,
+		&deletedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
 	if err != nil {
 		return nil, err
 	}
+	if publishedAt.Valid {
+		post.PublishedAt = &publishedAt.Time
+	}
+	if deletedAt.Valid {
+		post.DeletedAt = &deletedAt.Time
+	}
 	return post, nil
 }
 
-func (s *PostStore) List(limit, offset int) ([]*model.Post, error) {
+func scanPosts(rows *sql.Rows) ([]*model.Post, error) {
+	defer rows.Close()
+
+	var posts []*model.Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// GetByID returns a post unless it has been deleted.
+func (s *PostStore) GetByID(id int) (*model.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE id = $1 AND deleted_at IS NULL`
+	return scanPost(s.db.QueryRow(query, id))
+}
+
+// List returns a page of the public posts filter selects, most recently
+// published first.
+func (s *PostStore) List(filter model.PostFilter, page store.Page) ([]*model.Post, error) {
+	where, args := filterCondition(filter, nil)
+	cond, order, args := keyset([]string{"published_at", "id"}, page, args, func(c *store.Cursor) []interface{} {
+		return []interface{}{c.PublishedAt, c.ID}
+	})
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts
+		WHERE deleted_at IS NULL AND ` + publicCondition + where + cond + `
+		ORDER BY ` + order + `
+		LIMIT ` + strconv.Itoa(page.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := scanPosts(rows)
+	if err != nil {
+		return nil, err
+	}
+	return inListingOrder(posts, page), nil
+}
+
+// Count returns the number of public posts filter selects.
+func (s *PostStore) Count(filter model.PostFilter) (int, error) {
+	where, args := filterCondition(filter, nil)
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL AND `+publicCondition+where, args...).Scan(&n)
+	return n, err
+}
+
+// filterCondition returns the condition that applies filter, starting with
+// " AND " if there is one, with its values appended to args.
+func filterCondition(filter model.PostFilter, args []interface{}) (string, []interface{}) {
+	var cond strings.Builder
+	add := func(clause string, value interface{}) {
+		args = append(args, value)
+		cond.WriteString(" AND " + strings.ReplaceAll(clause, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if len(filter.Tags) > 0 {
+		// Both operators can use the GIN index on tags.
+		if filter.MatchAllTags {
+			add("tags @> ?", pq.Array(filter.Tags))
+		} else {
+			add("tags && ?", pq.Array(filter.Tags))
+		}
+	}
+	if filter.UserID != 0 {
+		add("user_id = ?", filter.UserID)
+	}
+	if filter.CreatedAfter != nil {
+		add("created_at > ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		add("created_at < ?", *filter.CreatedBefore)
+	}
+	return cond.String(), args
+}
+
+// keyset returns the condition and ORDER BY clause that select page from a
+// listing sorted by keys, descending. The condition starts with " AND " if
+// there is one, and refers to the cursor's values, as given by values, by
+// their position after args. Pages before a cursor are read in ascending
+// order, closest to the cursor first, so inListingOrder has to flip them.
+func keyset(keys []string, page store.Page, args []interface{}, values func(*store.Cursor) []interface{}) (string, string, []interface{}) {
+	desc := make([]string, len(keys))
+	asc := make([]string, len(keys))
+	for i, key := range keys {
+		desc[i] = key + " DESC"
+		asc[i] = key + " ASC"
+	}
+
+	cursor, op, order := page.After, "<", desc
+	if page.Before != nil {
+		cursor, op, order = page.Before, ">", asc
+	}
+	if cursor == nil {
+		return "", strings.Join(desc, ", "), args
+	}
+
+	params := make([]string, len(keys))
+	for i, v := range values(cursor) {
+		args = append(args, v)
+		params[i] = "$" + strconv.Itoa(len(args))
+	}
+	cond := " AND (" + strings.Join(keys, ", ") + ") " + op + " (" + strings.Join(params, ", ") + ")"
+	return cond, strings.Join(order, ", "), args
+}
+
+// inListingOrder undoes the reversed reading of pages before a cursor.
+func inListingOrder(posts []*model.Post, page store.Page) []*model.Post {
+	if page.Before != nil {
+		for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
+		}
+	}
+	return posts
+}
+
+// ListByUser returns a user's posts whatever their status, newest first.
+func (s *PostStore) ListByUser(userID, limit, offset int) ([]*model.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := s.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanPosts(rows)
+}
+
+// SetStatus changes the status of a post and the time it is published at.
+func (s *PostStore) SetStatus(id int, status model.PostStatus, publishedAt *time.Time) error {
+	_, err := s.db.Exec(`UPDATE posts SET status = $2, published_at = $3 WHERE id = $1`, id, status, publishedAt)
+	return err
+}
+
+// PublishDue marks scheduled posts whose time has come by now as published
+// and returns how many there were.
+func (s *PostStore) PublishDue(now time.Time) (int, error) {
+	res, err := s.db.Exec(`UPDATE posts SET status = 'published' WHERE status = 'scheduled' AND published_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// GetIDBySlug returns the ID of the post a slug belongs to, whether it is
+// the post's current slug or one it had before being renamed. post_slugs
+// holds both, but a post's current slug wins should they ever disagree.
+func (s *PostStore) GetIDBySlug(slug string) (int, error) {
+	query := `
+		SELECT id FROM (
+			SELECT id, 0 AS rank FROM posts WHERE slug = $1
+			UNION ALL
+			SELECT post_id, 1 FROM post_slugs WHERE slug = $1
+		) found
+		ORDER BY rank
+		LIMIT 1`
+
+	var id int
+	err := s.db.QueryRow(query, slug).Scan(&id)
+	return id, err
+}
+
+// SlugTaken reports whether slug belongs to a post other than postID, now
+// or in the past.
+func (s *PostStore) SlugTaken(slug string, postID int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM post_slugs WHERE slug = $1 AND post_id <> $2)`
+
+	var taken bool
+	err := s.db.QueryRow(query, slug, postID).Scan(&taken)
+	return taken, err
+}
+
+// SetSlug changes the slug of a post. Every slug a post has had stays
+// registered to it in post_slugs, so links to old ones can be redirected
+// and no other post can take them. If another post has or had the slug,
+// the primary key of post_slugs rejects it and store.ErrSlugTaken is
+// returned.
+func (s *PostStore) SetSlug(id int, slug string) error {
+	err := s.savepoint("set_slug", func() error {
+		_, err := s.db.Exec(`
+			INSERT INTO post_slugs (slug, post_id)
+			SELECT $2, $1 WHERE NOT EXISTS (SELECT 1 FROM post_slugs WHERE slug = $2 AND post_id = $1)`, id, slug)
+		if err != nil {
+			return err
+		}
+		_, err = s.db.Exec(`UPDATE posts SET slug = $2 WHERE id = $1`, id, slug)
+		return err
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" { // unique_violation
+		return store.ErrSlugTaken
+	}
+	return err
+}
+
+// savepoint runs fn inside a savepoint when the store works in a
+// transaction, so that a failed statement only undoes fn instead of
+// aborting the whole transaction.
+func (s *PostStore) savepoint(name string, fn func() error) error {
+	if _, ok := s.db.(*sql.Tx); !ok {
+		return fn()
+	}
+	if _, err := s.db.Exec("SAVEPOINT " + name); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := s.db.Exec("ROLLBACK TO SAVEPOINT " + name); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	_, err := s.db.Exec("RELEASE SAVEPOINT " + name)
+	return err
+}
+
+// ListWithoutSlug returns the posts that have no slug yet, deleted ones
+// included.
+func (s *PostStore) ListWithoutSlug() ([]*model.Post, error) {
+	rows, err := s.db.Query(`SELECT ` + postColumns + ` FROM posts WHERE slug IS NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanPosts(rows)
+}
+
+// SoftDelete moves a post to the trash. Deleting a post twice keeps the
+// time it was first deleted.
+func (s *PostStore) SoftDelete(id int) error {
+	_, err := s.db.Exec(`UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id)
+	return err
+}
+
+// Undelete takes a post out of the trash.
+func (s *PostStore) Undelete(id int) error {
+	_, err := s.db.Exec(`UPDATE posts SET deleted_at = NULL WHERE id = $1`, id)
+	return err
+}
+
+// GetDeleted returns a post that is in the trash.
+func (s *PostStore) GetDeleted(id int) (*model.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE id = $1 AND deleted_at IS NOT NULL`
+	return scanPost(s.db.QueryRow(query, id))
+}
+
+// ListDeleted returns a user's deleted posts, most recently deleted first.
+func (s *PostStore) ListDeleted(userID int) ([]*model.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE user_id = $1 AND deleted_at IS NOT NULL ORDER BY deleted_at DESC`
+	rows, err := s.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanPosts(rows)
+}
+
+// ListDeletedBefore returns every post deleted before cutoff.
+func (s *PostStore) ListDeletedBefore(cutoff time.Time) ([]*model.Post, error) {
+	query := `SELECT ` + postColumns + ` FROM posts WHERE deleted_at < $1 ORDER BY deleted_at`
+	rows, err := s.db.Query(query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	return scanPosts(rows)
+}
+
+// Purge permanently removes a deleted post together with its history. It
+// returns sql.ErrNoRows if the post is not in the trash, e.g. because it
+// was restored in the meantime.
+func (s *PostStore) Purge(id int) error {
+	res, err := s.db.Exec(`DELETE FROM posts WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *PostStore) CreateHistory(history *model.PostHistory) error {
 	query := `
-		SELECT id, user_id, title, sub_title, image, tags, content_path, version, created_at, updated_at 
-		FROM posts 
-		ORDER BY created_at DESC 
-		LIMIT $1 OFFSET $2`
+		INSERT INTO post_history (post_id, version, content_path, title, sub_title, image, tags, author_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0))`
+	_, err := s.db.Exec(query, history.PostID, history.Version, history.ContentPath,
+		history.Title, history.SubTitle, history.Image, pq.Array(history.Tags), history.AuthorID)
+	return err
+}
+
+// historyColumns is shared by the history queries. Versions archived before
+// metadata snapshots existed have NULLs, which are read back as zero values.
+// Snapshots always store a title, if only an empty one, so a NULL title
+// tells those versions apart.
+const historyColumns = `id, post_id, version, content_path, COALESCE(title, ''), COALESCE(sub_title, ''),
+	COALESCE(image, ''), tags, COALESCE(author_id, 0), created_at, title IS NOT NULL`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanHistory(row rowScanner) (*model.PostHistory, error) {
+	h := &model.PostHistory{}
+	err := row.Scan(&h.ID, &h.PostID, &h.Version, &h.ContentPath, &h.Title, &h.SubTitle,
+		&h.Image, pq.Array(&h.Tags), &h.AuthorID, &h.CreatedAt, &h.MetadataCaptured)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
 
-	rows, err := s.db.Query(query, limit, offset)
+func (s *PostStore) ListHistory(postID int) ([]*model.PostHistory, error) {
+	query := `SELECT ` + historyColumns + ` FROM post_history WHERE post_id = $1 ORDER BY version DESC`
+
+	rows, err := s.db.Query(query, postID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []*model.Post
+	var history []*model.PostHistory
 	for rows.Next() {
-		post := &model.Post{}
-		if err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.SubTitle, &post.Image, pq.Array(&post.Tags), &post.ContentPath,
-			&post.Version, &post.CreatedAt, &post.UpdatedAt,
-		); err != nil {
+		h, err := scanHistory(rows)
+		if err != nil {
 			return nil, err
 		}
-		posts = append(posts, post)
+		history = append(history, h)
 	}
 
-	if err = rows.Err(); err != nil {
+	return history, rows.Err()
+}
+
+func (s *PostStore) GetHistory(postID, version int) (*model.PostHistory, error) {
+	query := `SELECT ` + historyColumns + ` FROM post_history WHERE post_id = $1 AND version = $2`
+	return scanHistory(s.db.QueryRow(query, postID, version))
+}
+
+func (s *PostStore) ListContentPaths() ([]string, error) {
+	query := `
+		SELECT content_path FROM posts WHERE content_path IS NOT NULL
+		UNION
+		SELECT content_path FROM post_history`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
 		return nil, err
 	}
-	return posts, nil
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, rows.Err()
 }
 
-func (s *PostStore) CreateHistory(history *model.PostHistory) error {
-	query := `INSERT INTO post_history (post_id, version, content_path) VALUES ($1, $2, $3)`
-	_, err := s.db.Exec(query, history.PostID, history.Version, history.ContentPath)
+func (s *PostStore) ReplaceContentPath(oldPath, newPath string) error {
+	if _, err := s.db.Exec(`UPDATE posts SET content_path = $2 WHERE content_path = $1`, oldPath, newPath); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`UPDATE post_history SET content_path = $2 WHERE content_path = $1`, oldPath, newPath)
 	return err
 }
 
-func (s *PostStore) Search(query string, limit, offset int) ([]*model.Post, error) {
+func (s *PostStore) Search(query string, page store.Page) ([]*model.Post, error) {
 	// plainto_tsquery is used for user-provided search terms.
 	// It's safer and handles multiple words well.
+	rank := `ts_rank(title_tsv, plainto_tsquery('english', $1))`
+	cond, order, args := keyset([]string{rank, "published_at", "id"}, page, []interface{}{query}, func(c *store.Cursor) []interface{} {
+		return []interface{}{c.Rank, c.PublishedAt, c.ID}
+	})
 	sqlQuery := `
-		SELECT id, user_id, title, sub_title, image, tags, content_path, version, created_at, updated_at
+		SELECT ` + postColumns + `, ` + rank + `
 		FROM posts
-		WHERE title_tsv @@ plainto_tsquery('english', $1)
-		ORDER BY ts_rank(title_tsv, plainto_tsquery('english', $1)) DESC
-		LIMIT $2 OFFSET $3`
+		WHERE title_tsv @@ plainto_tsquery('english', $1) AND deleted_at IS NULL AND ` + publicCondition + cond + `
+		ORDER BY ` + order + `
+		LIMIT ` + strconv.Itoa(page.Limit)
 
-	rows, err := s.db.Query(sqlQuery, query, limit, offset)
+	rows, err := s.db.Query(sqlQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -106,15 +479,26 @@ func (s *PostStore) Search(query string, limit, offset int) ([]*model.Post, erro
 
 	var posts []*model.Post
 	for rows.Next() {
-		post := &model.Post{}
-		if err := rows.Scan(
-			&post.ID, &post.UserID, &post.Title, &post.SubTitle, &post.Image, pq.Array(&post.Tags), &post.ContentPath,
-			&post.Version, &post.CreatedAt, &post.UpdatedAt,
-		); err != nil {
+		var rank float32
+		post, err := scanPost(rows, &rank)
+		if err != nil {
 			return nil, err
 		}
+		post.Rank = rank
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return inListingOrder(posts, page), nil
+}
 
-	return posts, rows.Err()
-}
\ No newline at end of file
+// CountSearch returns the number of public posts matching query.
+func (s *PostStore) CountSearch(query string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM posts
+		WHERE title_tsv @@ plainto_tsquery('english', $1) AND deleted_at IS NULL AND `+publicCondition, query).Scan(&n)
+	return n, err
+}
@@ -56,3 +56,19 @@ func (s *UserStore) GetByID(id int) (*model.User, error) {
 	}
 	return user, nil
 }
+
+func (s *UserStore) GetByUsername(username string) (*model.User, error) {
+	query := `SELECT id, username, email, created_at, updated_at FROM users WHERE username = $1`
+	user := &model.User{}
+	err := s.db.QueryRow(query, username).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
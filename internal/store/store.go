@@ -1,6 +1,15 @@
 package store
 
-import "go-blog/internal/model"
+import (
+	"errors"
+	"time"
+
+	"go-blog/internal/model"
+)
+
+// ErrSlugTaken is returned by PostStore.SetSlug when another post got the
+// slug first.
+var ErrSlugTaken = errors.New("slug taken")
 
 // UserStore defines the interface for user data persistence.
 type UserStore interface {
@@ -8,14 +17,99 @@ type UserStore interface {
 	GetByEmail(email string) (*model.User, error)
 	// Login is handled in the service layer, so no change here is needed.
 	GetByID(id int) (*model.User, error)
+	GetByUsername(username string) (*model.User, error)
+}
+
+// Cursor is the position of a post in a listing, given by the keys the
+// listing is sorted on: the search rank, for search results, then the
+// publish time and ID.
+type Cursor struct {
+	Rank        float32
+	PublishedAt time.Time
+	ID          int
+}
+
+// Page selects up to Limit posts of a listing that come after After, or
+// before Before. With neither set it starts at the top. Posts are returned
+// in listing order either way.
+type Page struct {
+	Limit  int
+	After  *Cursor
+	Before *Cursor
 }
 
 // PostStore defines the interface for post data persistence.
 type PostStore interface {
 	Create(post *model.Post) (*model.Post, error)
 	Update(post *model.Post) (*model.Post, error)
+	// GetByID, List and Search leave out deleted posts. List and Search
+	// also leave out posts that are not public yet, or any more.
 	GetByID(id int) (*model.Post, error)
-	List(limit, offset int) ([]*model.Post, error)
+	// List pages through the public posts filter selects, newest first.
+	List(filter model.PostFilter, page Page) ([]*model.Post, error)
+	// Count returns the number of posts List pages through.
+	Count(filter model.PostFilter) (int, error)
+	// ListByUser returns a user's posts whatever their status, newest first.
+	ListByUser(userID, limit, offset int) ([]*model.Post, error)
+	// SetStatus changes the status of a post and the time it is published at.
+	SetStatus(id int, status model.PostStatus, publishedAt *time.Time) error
+	// PublishDue marks scheduled posts due by now as published and returns
+	// how many there were.
+	PublishDue(now time.Time) (int, error)
+	// GetIDBySlug returns the ID of the post a slug belongs to, whether it
+	// is the post's current slug or an old one.
+	GetIDBySlug(slug string) (int, error)
+	// SlugTaken reports whether slug belongs, or belonged, to a post other
+	// than postID.
+	SlugTaken(slug string, postID int) (bool, error)
+	// SetSlug changes the slug of a post, keeping the old one for redirects.
+	// It returns ErrSlugTaken if another post has or had the slug, and leaves
+	// an enclosing transaction usable then.
+	SetSlug(id int, slug string) error
+	// ListWithoutSlug returns the posts that have no slug yet.
+	ListWithoutSlug() ([]*model.Post, error)
+	// SoftDelete moves a post to the trash and Undelete takes it out again.
+	SoftDelete(id int) error
+	Undelete(id int) error
+	// GetDeleted returns a post that is in the trash.
+	GetDeleted(id int) (*model.Post, error)
+	// ListDeleted returns a user's deleted posts, most recently deleted first.
+	ListDeleted(userID int) ([]*model.Post, error)
+	// ListDeletedBefore returns every post deleted before cutoff.
+	ListDeletedBefore(cutoff time.Time) ([]*model.Post, error)
+	// Purge permanently removes a deleted post and its history.
+	Purge(id int) error
 	CreateHistory(history *model.PostHistory) error
-	Search(query string, limit, offset int) ([]*model.Post, error)
-}
\ No newline at end of file
+	// ListHistory returns the archived versions of a post, newest first.
+	ListHistory(postID int) ([]*model.PostHistory, error)
+	GetHistory(postID, version int) (*model.PostHistory, error)
+	// ListContentPaths returns every content path referenced by a post or
+	// by one of its archived versions.
+	ListContentPaths() ([]string, error)
+	// ReplaceContentPath points every post and archived version that
+	// references oldPath at newPath instead.
+	ReplaceContentPath(oldPath, newPath string) error
+	// Search pages through public posts matching query, best match first.
+	// The posts have their Rank set.
+	Search(query string, page Page) ([]*model.Post, error)
+	// CountSearch returns the number of posts Search pages through.
+	CountSearch(query string) (int, error)
+}
+
+// MediaStore defines the interface for media metadata persistence.
+type MediaStore interface {
+	Create(media *model.Media) (*model.Media, error)
+	GetByID(id int) (*model.Media, error)
+	GetByPath(path string) (*model.Media, error)
+	// ListByUser returns a user's media, newest first.
+	ListByUser(userID int) ([]*model.Media, error)
+	// ListPaths returns the storage path of every media file and variant.
+	ListPaths() ([]string, error)
+}
+
+// UnitOfWork groups several store calls into a single atomic operation.
+type UnitOfWork interface {
+	// Do calls fn with a PostStore whose calls all share one transaction.
+	// The transaction is committed when fn returns nil and rolled back otherwise.
+	Do(fn func(posts PostStore) error) error
+}
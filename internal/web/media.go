@@ -0,0 +1,82 @@
+package web
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go-blog/internal/model"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// mediaURLPattern matches the URLs the media library hands out.
+var mediaURLPattern = regexp.MustCompile(`^/media/(\d+)$`)
+
+// MediaLookup returns a media library item, including its variants.
+type MediaLookup func(id int) (*model.Media, error)
+
+// ImageSizes is the sizes attribute paired with Srcset: images span the
+// content column, which is at most about 720px wide.
+const ImageSizes = "(min-width: 768px) 720px, 100vw"
+
+// Srcset returns a srcset attribute value listing the resized variants a
+// media library image has, followed by the original, or "" for any other
+// URL. Images too small for any variant have no srcset either.
+func Srcset(url string, lookup MediaLookup) string {
+	m := mediaURLPattern.FindStringSubmatch(url)
+	if m == nil {
+		return ""
+	}
+	id, err := strconv.Atoi(m[1])
+	if err != nil {
+		return ""
+	}
+	media, err := lookup(id)
+	if err != nil || len(media.Variants) == 0 {
+		return ""
+	}
+
+	candidates := make([]string, 0, len(media.Variants)+1)
+	for _, v := range media.Variants {
+		candidates = append(candidates, fmt.Sprintf("%s?size=%s %dw", url, v.Name, v.Width))
+	}
+	if media.Width > 0 {
+		candidates = append(candidates, fmt.Sprintf("%s %dw", url, media.Width))
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// SizedImage returns the URL of the named variant of a media library
+// image. Other URLs are returned unchanged.
+func SizedImage(url, size string) string {
+	if !mediaURLPattern.MatchString(url) {
+		return url
+	}
+	return url + "?size=" + size
+}
+
+// AddImageSrcset adds srcset and sizes attributes to every media library
+// image in a parsed markdown document that has resized variants.
+func AddImageSrcset(doc ast.Node, lookup MediaLookup) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		img, ok := node.(*ast.Image)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		srcset := Srcset(string(img.Destination), lookup)
+		if srcset == "" {
+			return ast.GoToNext
+		}
+		if img.Attribute == nil {
+			img.Attribute = &ast.Attribute{}
+		}
+		if img.Attrs == nil {
+			img.Attrs = map[string][]byte{}
+		}
+		img.Attrs["srcset"] = []byte(srcset)
+		img.Attrs["sizes"] = []byte(ImageSizes)
+		return ast.GoToNext
+	})
+}
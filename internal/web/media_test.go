@@ -0,0 +1,67 @@
+package web
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go-blog/internal/model"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// lookupMedia knows media 3, an image 1000px wide with two variants, and
+// media 4, which is too small for any.
+func lookupMedia(id int) (*model.Media, error) {
+	switch id {
+	case 3:
+		return &model.Media{ID: 3, Width: 1000, Variants: []model.MediaVariant{
+			{Name: "thumb", Width: 320},
+			{Name: "medium", Width: 800},
+		}}, nil
+	case 4:
+		return &model.Media{ID: 4, Width: 200}, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestSrcset(t *testing.T) {
+	assert.Equal(t, "/media/3?size=thumb 320w, /media/3?size=medium 800w, /media/3 1000w", Srcset("/media/3", lookupMedia))
+	assert.Empty(t, Srcset("/media/4", lookupMedia))
+	assert.Empty(t, Srcset("/media/5", lookupMedia))
+	assert.Empty(t, Srcset("https://example.com/cover.jpg", lookupMedia))
+	assert.Empty(t, Srcset("/media/3?size=thumb", lookupMedia))
+
+	assert.Equal(t, "/media/3?size=large", SizedImage("/media/3", "large"))
+	assert.Equal(t, "/assets/img/home-bg.jpg", SizedImage("/assets/img/home-bg.jpg", "large"))
+}
+
+func TestAddImageSrcset(t *testing.T) {
+	doc := parser.New().Parse([]byte("![cover](/media/3)\n\n![hotlinked](https://example.com/a.png)\n"))
+	AddImageSrcset(doc, lookupMedia)
+	out := string(markdown.Render(doc, html.NewRenderer(html.RendererOptions{Flags: html.CommonFlags})))
+
+	assert.Contains(t, out, `srcset="/media/3?size=thumb 320w`)
+	assert.Equal(t, 1, strings.Count(out, "srcset="))
+}
+
+func TestHeaderCoverSrcset(t *testing.T) {
+	r := NewTemplateRenderer(lookupMedia)
+	render := func(image string) string {
+		var out strings.Builder
+		err := r.Render(&out, "_header.html", map[string]interface{}{"Post": &model.Post{Title: "Hello", Image: image}}, nil)
+		assert.NoError(t, err)
+		return out.String()
+	}
+
+	cover := render("/media/3")
+	assert.Contains(t, cover, `src="/media/3?size=large"`)
+	assert.Contains(t, cover, `srcset="/media/3?size=thumb 320w, /media/3?size=medium 800w, /media/3 1000w"`)
+	assert.NotContains(t, cover, "background-image")
+
+	// Posts without a cover keep the default background.
+	assert.Contains(t, render(""), "/assets/img/post-bg.jpg")
+}
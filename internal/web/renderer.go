@@ -4,6 +4,7 @@ import (
 	"embed"
 	"html/template"
 	"io"
+	"net/url"
 
 	"go-blog/internal/middleware"
 
@@ -19,8 +20,9 @@ type TemplateRenderer struct {
 	templates *template.Template
 }
 
-// NewTemplateRenderer creates a new TemplateRenderer.
-func NewTemplateRenderer() *TemplateRenderer {
+// NewTemplateRenderer creates a new TemplateRenderer. media is used to
+// list the variants of media library images in srcset attributes.
+func NewTemplateRenderer(media MediaLookup) *TemplateRenderer {
 	// Define a function map to be used in the templates.
 	funcMap := template.FuncMap{
 		"t": func(c echo.Context, messageID string) string {
@@ -46,6 +48,10 @@ func NewTemplateRenderer() *TemplateRenderer {
 			}
 			return translated
 		},
+		"srcset":     func(url string) string { return Srcset(url, media) },
+		"sized":      SizedImage,
+		"imageSizes": func() string { return ImageSizes },
+		"pathEscape": url.PathEscape,
 	}
 
 	return &TemplateRenderer{
@@ -0,0 +1,5 @@
+ALTER TABLE post_history DROP COLUMN author_id;
+ALTER TABLE post_history DROP COLUMN tags;
+ALTER TABLE post_history DROP COLUMN image;
+ALTER TABLE post_history DROP COLUMN sub_title;
+ALTER TABLE post_history DROP COLUMN title;
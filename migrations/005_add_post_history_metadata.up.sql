@@ -0,0 +1,8 @@
+-- Snapshot the post metadata alongside the markdown file for every archived version.
+-- Rows written before this migration have no snapshot and keep NULLs here.
+ALTER TABLE post_history ADD COLUMN title VARCHAR(255);
+ALTER TABLE post_history ADD COLUMN sub_title VARCHAR(255);
+ALTER TABLE post_history ADD COLUMN image VARCHAR(255);
+ALTER TABLE post_history ADD COLUMN tags TEXT[];
+-- The user who wrote this version.
+ALTER TABLE post_history ADD COLUMN author_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
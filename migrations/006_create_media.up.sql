@@ -0,0 +1,11 @@
+CREATE TABLE media (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    path VARCHAR(512) NOT NULL UNIQUE, -- Path to file in storage (e.g., S3 key)
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    original_name VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_media_user_id ON media(user_id);
@@ -0,0 +1,14 @@
+-- Dimensions of the original upload. NULL when it couldn't be decoded.
+ALTER TABLE media ADD COLUMN width INTEGER;
+ALTER TABLE media ADD COLUMN height INTEGER;
+
+-- Resized copies of an image, e.g. "thumb", "medium" and "large".
+CREATE TABLE media_variants (
+    media_id INTEGER NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    name VARCHAR(20) NOT NULL,
+    path VARCHAR(512) NOT NULL UNIQUE, -- Path to file in storage (e.g., S3 key)
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size BIGINT NOT NULL,
+    PRIMARY KEY (media_id, name)
+);
@@ -0,0 +1,3 @@
+-- Deleted posts stay in the trash until the purge job removes them.
+ALTER TABLE posts ADD COLUMN deleted_at TIMESTAMPTZ;
+CREATE INDEX idx_posts_deleted_at ON posts(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Posts are only public once published. Existing posts were all live, so
+-- they start out published as of their creation.
+ALTER TABLE posts ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'published', 'scheduled', 'archived'));
+-- When the post went live, or for scheduled posts when it will.
+ALTER TABLE posts ADD COLUMN published_at TIMESTAMPTZ;
+UPDATE posts SET published_at = created_at;
+CREATE INDEX idx_posts_status_published_at ON posts(status, published_at);
@@ -0,0 +1,2 @@
+DROP TABLE post_slugs;
+ALTER TABLE posts DROP COLUMN slug;
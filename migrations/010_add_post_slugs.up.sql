@@ -0,0 +1,13 @@
+-- Posts get a URL slug derived from their title. Existing posts have none
+-- until `blogctl posts slugs` fills them in; they stay reachable by ID.
+ALTER TABLE posts ADD COLUMN slug VARCHAR(100) UNIQUE;
+
+-- Slugs a post had before it was renamed, so old links can redirect. A
+-- slug belongs to one post for good, current or not.
+CREATE TABLE post_slugs (
+    slug VARCHAR(100) PRIMARY KEY,
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_post_slugs_post_id ON post_slugs(post_id);
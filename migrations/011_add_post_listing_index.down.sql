@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_posts_published_at_id;
@@ -0,0 +1,3 @@
+-- Public listings are paged by (published_at, id), newest first. Only live
+-- posts are listed, so the trash stays out of the index.
+CREATE INDEX idx_posts_published_at_id ON posts(published_at DESC, id DESC) WHERE deleted_at IS NULL;
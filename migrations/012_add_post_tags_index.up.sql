@@ -0,0 +1,2 @@
+-- Lets listings filter by tag with && (any of) and @> (all of).
+CREATE INDEX idx_posts_tags ON posts USING GIN(tags);
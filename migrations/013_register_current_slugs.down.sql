@@ -0,0 +1,3 @@
+ALTER TABLE posts DROP CONSTRAINT posts_slug_registered;
+ALTER TABLE post_slugs DROP CONSTRAINT post_slugs_slug_post_id_key;
+DELETE FROM post_slugs s USING posts p WHERE p.id = s.post_id AND p.slug = s.slug;
@@ -0,0 +1,14 @@
+-- post_slugs now holds every slug a post has or had, current ones included,
+-- so its primary key alone keeps two posts from ever sharing a slug.
+-- posts.slug points at the current one.
+
+-- Old slugs another post has since taken stay with that post.
+DELETE FROM post_slugs s USING posts p WHERE p.slug = s.slug AND p.id <> s.post_id;
+INSERT INTO post_slugs (slug, post_id)
+SELECT slug, id FROM posts WHERE slug IS NOT NULL
+ON CONFLICT (slug) DO NOTHING;
+
+-- A post's current slug must be registered to that post.
+ALTER TABLE post_slugs ADD CONSTRAINT post_slugs_slug_post_id_key UNIQUE (slug, post_id);
+ALTER TABLE posts ADD CONSTRAINT posts_slug_registered
+    FOREIGN KEY (slug, id) REFERENCES post_slugs (slug, post_id);